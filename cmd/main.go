@@ -1,36 +1,62 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/AntonCkya/ozon_habr/graph"
+	"github.com/AntonCkya/ozon_habr/internal/apq"
 	"github.com/AntonCkya/ozon_habr/internal/auth"
+	"github.com/AntonCkya/ozon_habr/internal/cache"
+	"github.com/AntonCkya/ozon_habr/internal/config"
 	"github.com/AntonCkya/ozon_habr/internal/db"
+	"github.com/AntonCkya/ozon_habr/internal/gqlimits"
 	rest_handler "github.com/AntonCkya/ozon_habr/internal/handler"
+	"github.com/AntonCkya/ozon_habr/internal/health"
+	"github.com/AntonCkya/ozon_habr/internal/limiter"
+	"github.com/AntonCkya/ozon_habr/internal/logging"
 	"github.com/AntonCkya/ozon_habr/internal/mem_repository"
+	"github.com/AntonCkya/ozon_habr/internal/metrics"
 	"github.com/AntonCkya/ozon_habr/internal/pg_repository"
+	"github.com/AntonCkya/ozon_habr/internal/server"
+	"github.com/AntonCkya/ozon_habr/internal/storage"
+	"github.com/AntonCkya/ozon_habr/internal/tracing"
+	"github.com/AntonCkya/ozon_habr/internal/validation"
+	"github.com/AntonCkya/ozon_habr/internal/wsutil"
+	"github.com/AntonCkya/ozon_habr/migrations"
 	"github.com/gorilla/websocket"
 	"github.com/rs/cors"
 )
 
-const defaultPort = "8080"
-
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+	cfg := config.Load()
+	slog.SetDefault(logging.New(os.Stdout, cfg.LogFormat, cfg.LogLevel))
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.TracingExporter, cfg.TracingSampleRatio)
+	if err != nil {
+		fatal("failed to init tracing", err)
 	}
+	defer shutdownTracing(context.Background())
 
 	deployType := flag.String("d", "", "deploy type (d (in Docker) or n (native))")
 	storageType := flag.String("s", "", "storage type (m (in memory) or p (postgres))")
+	repair := flag.Bool("repair", false, "recompute post comment counters and exit (only with -s p)")
 
 	flag.Parse()
 	if *storageType != "m" && *storageType != "p" {
@@ -43,9 +69,17 @@ func main() {
 		flag.Usage()
 		os.Exit(1)
 	}
+	if *repair && *storageType != "p" {
+		fmt.Println("-repair works only with -s p")
+		flag.Usage()
+		os.Exit(1)
+	}
 
 	var userRepo graph.UserRepoInterface
+	var loginAttempts limiter.Store
+	var apqCache graphql.Cache[string] = lru.New[string](cfg.APQCacheSize)
 	var resolver *graph.Resolver
+	checker := health.New()
 	var Host string
 	if *deployType == "d" {
 		Host = "db"
@@ -54,57 +88,234 @@ func main() {
 	}
 
 	if *storageType == "p" {
-		pg, err := db.InitDB(db.DBConfig{
+		dbConfig := db.DBConfig{
 			Host:     Host,
 			Port:     "5432",
 			User:     "postgres",
 			Password: "postgres",
 			DBName:   "ozon_habr",
 			SSLMode:  "disable",
-		})
+
+			Driver:          cfg.DBDriver,
+			MaxOpenConns:    cfg.DBMaxOpenConns,
+			MaxIdleConns:    cfg.DBMaxIdleConns,
+			ConnMaxLifetime: cfg.DBConnMaxLife,
+			ConnMaxIdleTime: cfg.DBConnMaxIdle,
+		}
+		pg, err := db.Connect(context.Background(), dbConfig, cfg.DBConnectTimeout)
 
 		if err != nil {
-			log.Fatalf("Failed to connect to database: %v", err)
+			fatal("failed to connect to database", err)
 		}
 		defer db.CloseDB(pg)
+		// volume с init.sql postgres выполняет только при создании базы,
+		// поэтому схему существующей базы обновляем сами
+		if err := db.Migrate(context.Background(), pg, migrations.InitSQL); err != nil {
+			fatal("failed to migrate database", err)
+		}
+		metrics.RegisterDB(pg, "ozon_habr")
+		checker.AddCheck("database", pg.PingContext)
+		checker.AddCheck("migrations", func(ctx context.Context) error {
+			return db.CheckSchema(ctx, pg)
+		})
 
-		resolver = graph.NewPgResolver(pg)
-		userRepo = pg_repository.NewUserRepository(pg)
+		var replicas []*sql.DB
+		for i, replicaHost := range cfg.DBReplicaHosts {
+			replicaConfig := dbConfig
+			replicaConfig.Host, replicaConfig.Port = splitHostPort(replicaHost, dbConfig.Port)
+			replica, err := db.Connect(context.Background(), replicaConfig, cfg.DBConnectTimeout)
+			if err != nil {
+				fatal("failed to connect to replica "+replicaHost, err)
+			}
+			defer db.CloseDB(replica)
+			name := fmt.Sprintf("replica_%d", i+1)
+			metrics.RegisterDB(replica, "ozon_habr_"+name)
+			checker.AddCheck(name, replica.PingContext)
+			replicas = append(replicas, replica)
+		}
+		cluster := pg_repository.NewCluster(pg, replicas...).
+			WithStickyPrimary(cfg.DBStickyWindow, auth.GetUserID).
+			WithQueryTimeout(cfg.DBQueryTimeout)
+		// отложенные вызовы идут в обратном порядке: запросы закрываются раньше пулов
+		defer func() {
+			if err := cluster.Close(); err != nil {
+				slog.Error("failed to close prepared statements", "error", err)
+			}
+		}()
+		if len(replicas) > 0 {
+			slog.Info("read replicas enabled", "replicas", len(replicas), "sticky_window", cfg.DBStickyWindow)
+		}
+
+		if *repair {
+			fixed, err := pg_repository.NewPostRepository(cluster).RepairCommentStats(context.Background())
+			if err != nil {
+				fatal("failed to repair comment stats", err)
+			}
+			slog.Info("comment stats repaired", "posts", fixed)
+			return
+		}
+
+		resolver = graph.NewPgResolver(cluster)
+		userRepo = resolver.UserRepo
+		loginAttempts = pg_repository.NewLoginAttemptRepository(cluster)
+		if cfg.APQCache == "postgres" {
+			apqCache = apq.NewTiered(apqCache, pg_repository.NewPersistedQueryRepository(cluster))
+		}
 	}
 	if *storageType == "m" {
 		resolver = graph.NewMemResolver()
 		userRepo = mem_repository.NewUserRepository()
+		loginAttempts = mem_repository.NewLoginAttemptRepository()
+	}
+
+	if cfg.CacheTTL > 0 {
+		readCache := cache.NewLRU(cfg.CacheSize)
+		resolver.PostRepo = cache.NewPostRepository(resolver.PostRepo, readCache, cfg.CacheTTL)
+		resolver.CommentRepo = cache.NewCommentRepository(resolver.CommentRepo, readCache, cfg.CacheTTL)
 	}
 
+	blobs, err := storage.NewLocal(cfg.UploadDir, cfg.MediaURL)
+	if err != nil {
+		fatal("failed to init upload storage", err)
+	}
+	resolver.Blobs = blobs
+	resolver.UploadMaxSize = cfg.UploadMaxSize
+
 	c := graph.Config{Resolvers: resolver}
 	c.Directives.IsAuthenticated = auth.AuthMiddleware
+	c.Directives.RateLimit = limiter.NewRateLimiter(limiter.ParseRates(cfg.RateLimits)).Directive
+	graph.SetComplexity(&c, cfg.CommentsPerPostEstimate)
 	srv := handler.New(graph.NewExecutableSchema(c))
 
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
+	// SSE должен идти раньше POST: он тоже принимает POST, но с Accept: text/event-stream
+	srv.AddTransport(transport.SSE{
+		KeepAlivePingInterval: 10 * time.Second,
+	})
 	srv.AddTransport(transport.POST{})
+	// запас на остальные поля multipart-запроса сверх самого файла
+	srv.AddTransport(transport.MultipartForm{
+		MaxUploadSize: cfg.UploadMaxSize + 1024*1024,
+		MaxMemory:     1024 * 1024,
+	})
 	srv.AddTransport(transport.Websocket{
 		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              auth.WebsocketInit(userRepo),
+		InitTimeout:           cfg.WSInitTimeout,
 		Upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true },
+			// graphql-transport-ws (Apollo, urql) и legacy subscriptions-transport-ws
+			Subprotocols: []string{"graphql-transport-ws", "graphql-ws"},
+			CheckOrigin:  wsutil.CheckOrigin(cfg.AllowedOrigins),
+			// без явного ReadBufferSize не работает ограничение размера сообщений, см. wsutil.LimitMessageSize
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
 		},
 	})
 
+	if cfg.PersistedQueriesManifest != "" {
+		// строгий режим: хэши берутся только из манифеста, новые запросы не регистрируются
+		manifest, err := apq.LoadManifest(cfg.PersistedQueriesManifest)
+		if err != nil {
+			fatal("failed to load persisted queries", err)
+		}
+		slog.Info("persisted queries allow-list enabled", "queries", manifest.Len())
+		srv.Use(extension.AutomaticPersistedQuery{Cache: manifest})
+		srv.Use(apq.AllowList{Manifest: manifest})
+	} else {
+		srv.Use(extension.AutomaticPersistedQuery{Cache: apqCache})
+	}
+	srv.Use(logging.GraphQL{UserID: auth.GetUserID})
+	srv.Use(metrics.NewGraphQL())
+	srv.Use(tracing.GraphQL{})
+	// ListLimit раньше сложности, чтобы огромный limit давал понятную ошибку LIMIT_EXCEEDED
+	srv.Use(gqlimits.ListLimit{MaxSize: cfg.MaxListLimit})
+	srv.Use(gqlimits.DepthLimit{MaxDepth: cfg.MaxQueryDepth})
+	srv.Use(extension.FixedComplexityLimit(cfg.MaxQueryComplexity))
+	// батчинг счётчиков пользователей в пределах одной операции
+	srv.AroundOperations(resolver.WithLoaders)
+
 	corsMiddleware := cors.New(cors.Options{
 		AllowCredentials: true,
-		AllowedOrigins:   []string{"*"},
+		AllowedOrigins:   cfg.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
 	})
 
 	http.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	http.Handle("/query", corsMiddleware.Handler(auth.Middleware(srv)))
+	http.Handle("/query", metrics.InstrumentHandler("query", corsMiddleware.Handler(auth.Middleware(userRepo)(wsutil.LimitMessageSize(cfg.WSMaxMessageSize, srv)))))
+	http.Handle("/metrics", metrics.Handler())
+	http.Handle("/media/", http.StripPrefix("/media", blobs.Handler()))
+
+	validator, err := validation.New(cfg.BreachedPasswordsFile)
+	if err != nil {
+		fatal("failed to load password validator", err)
+	}
+
+	loginLimiter := limiter.NewLoginLimiter(loginAttempts, limiter.DefaultUserPolicy, limiter.DefaultIPPolicy)
+	authHandler := rest_handler.NewAuthHandler(userRepo, resolver, validator, loginLimiter)
+	http.Handle("/auth/register", metrics.InstrumentHandler("register", http.HandlerFunc(authHandler.Register)))
+	http.Handle("/auth/login", metrics.InstrumentHandler("login", http.HandlerFunc(authHandler.Login)))
+	http.Handle("/auth/me", metrics.InstrumentHandler("me", http.HandlerFunc(authHandler.Me)))
+	http.Handle("/auth/password", metrics.InstrumentHandler("password", http.HandlerFunc(authHandler.ChangePassword)))
+	http.Handle("/auth/username", metrics.InstrumentHandler("username", http.HandlerFunc(authHandler.ChangeUsername)))
+	http.Handle("/auth/delete", metrics.InstrumentHandler("delete", http.HandlerFunc(authHandler.DeleteAccount)))
+
+	// пробы дёргаются часто, их не логируем
+	root := http.NewServeMux()
+	root.HandleFunc("/healthz", checker.Healthz)
+	root.HandleFunc("/readyz", checker.Readyz)
+	root.Handle("/", logging.RequestID(http.DefaultServeMux))
+
+	streams := server.NewStreams()
+	httpServer := server.New(":"+cfg.Port, streams.Middleware(root), server.Timeouts{
+		ReadHeader: cfg.HTTPReadHeaderTimeout,
+		Read:       cfg.HTTPReadTimeout,
+		Write:      cfg.HTTPWriteTimeout,
+		Idle:       cfg.HTTPIdleTimeout,
+	})
+	httpServer.RegisterOnShutdown(streams.Close)
 
-	authHandler := rest_handler.NewAuthHandler(userRepo)
-	http.Handle("/auth/register", http.HandlerFunc(authHandler.Register))
-	http.Handle("/auth/login", http.HandlerFunc(authHandler.Login))
-	http.Handle("/auth/me", http.HandlerFunc(authHandler.Me))
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go resolver.PublishScheduled(ctx, cfg.SchedulerInterval)
+	go loginLimiter.Cleanup(ctx, 10*time.Minute)
+	go resolver.SweepAttachments(ctx, time.Hour, cfg.AttachmentTTL)
+
+	go func() {
+		slog.Info("connect to http://localhost:" + cfg.Port + "/ for GraphQL playground")
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("server stopped", err)
+		}
+	}()
+
+	<-ctx.Done()
+	slog.Info("shutting down")
+	checker.SetShuttingDown()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	// Shutdown ждёт обычные запросы, подписки закрывает streams.Close
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to drain requests", "error", err)
+	}
+	if err := streams.Wait(shutdownCtx); err != nil {
+		slog.Error("failed to close subscriptions", "error", err)
+	}
+	slog.Info("server stopped")
+}
+
+// splitHostPort разбирает host или host:port из DB_REPLICA_HOSTS
+func splitHostPort(hostPort string, defaultPort string) (string, string) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return hostPort, defaultPort
+	}
+	return host, port
+}
 
-	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
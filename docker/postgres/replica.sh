@@ -0,0 +1,14 @@
+#!/bin/sh
+# Реплика для локальной проверки чтения с реплик: при первом запуске
+# снимает базовую копию с основной базы и дальше получает WAL потоком.
+set -e
+
+if [ ! -s "$PGDATA/PG_VERSION" ]; then
+	until pg_isready -h "$PRIMARY_HOST" -U postgres; do
+		sleep 1
+	done
+	PGPASSWORD="$POSTGRES_PASSWORD" pg_basebackup -h "$PRIMARY_HOST" -U postgres -D "$PGDATA" -R -X stream
+	chmod 700 "$PGDATA"
+fi
+
+exec postgres
@@ -0,0 +1,164 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/AntonCkya/ozon_habr/graph/model"
+	"github.com/AntonCkya/ozon_habr/internal/logging"
+	"github.com/AntonCkya/ozon_habr/internal/media"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+	"github.com/AntonCkya/ozon_habr/internal/storage"
+)
+
+const (
+	maxAttachmentsPerPost = 10
+	thumbnailSize         = 320
+)
+
+// attachmentIDs разбирает PostInput.attachmentIds, повторы убираются
+func attachmentIDs(ids []string) ([]int, error) {
+	seen := make(map[int]bool)
+	result := make([]int, 0, len(ids))
+	for _, id := range ids {
+		attachmentID, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert attachment id to int: %w", err)
+		}
+		if seen[attachmentID] {
+			continue
+		}
+		seen[attachmentID] = true
+		result = append(result, attachmentID)
+	}
+	if len(result) > maxAttachmentsPerPost {
+		return nil, fmt.Errorf("too many attachments, max %d", maxAttachmentsPerPost)
+	}
+	return result, nil
+}
+
+func (r *Resolver) attachmentToModel(attachment *repo_models.Attachment) *model.Attachment {
+	return &model.Attachment{
+		ID:           strconv.Itoa(attachment.ID),
+		URL:          r.Blobs.URL(attachment.Key),
+		ThumbnailURL: r.Blobs.URL(attachment.ThumbnailKey),
+		ContentType:  attachment.ContentType,
+		Size:         int32(attachment.Size),
+		Width:        int32(attachment.Width),
+		Height:       int32(attachment.Height),
+	}
+}
+
+func (r *Resolver) attachmentsToModel(attachments []*repo_models.Attachment) []*model.Attachment {
+	model_attachments := make([]*model.Attachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		model_attachments = append(model_attachments, r.attachmentToModel(attachment))
+	}
+	return model_attachments
+}
+
+// postAttachments - вложения одного поста для резолверов, которые собирают пост по одному
+func (r *Resolver) postAttachments(ctx context.Context, postID int) ([]*model.Attachment, error) {
+	attachments, err := r.AttachmentRepo.GetAttachmentsByPostIDs(ctx, []int{postID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+	return r.attachmentsToModel(attachments[postID]), nil
+}
+
+// storeUpload проверяет файл, кладёт его и превью в BlobStore и возвращает вложение без id.
+// Файл читается целиком: размер уже ограничен UploadMaxSize, а декодеру картинок всё равно нужен весь файл.
+func (r *Resolver) storeUpload(ctx context.Context, userID int, file graphql.Upload) (*repo_models.Attachment, error) {
+	if file.Size > r.UploadMaxSize {
+		return nil, fmt.Errorf("file is too large, max %d bytes", r.UploadMaxSize)
+	}
+	data, err := io.ReadAll(io.LimitReader(file.File, r.UploadMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if int64(len(data)) > r.UploadMaxSize {
+		return nil, fmt.Errorf("file is too large, max %d bytes", r.UploadMaxSize)
+	}
+
+	img, err := media.Process(data, thumbnailSize)
+	if err != nil {
+		return nil, err
+	}
+
+	key := storage.NewKey(img.Ext)
+	if err := r.Blobs.Put(ctx, key, img.ContentType, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to store file: %w", err)
+	}
+	thumbnailKey := storage.NewKey(img.ThumbnailExt)
+	if err := r.Blobs.Put(ctx, thumbnailKey, img.ThumbnailContentType, bytes.NewReader(img.Thumbnail)); err != nil {
+		r.Blobs.Delete(ctx, key)
+		return nil, fmt.Errorf("failed to store thumbnail: %w", err)
+	}
+
+	return &repo_models.Attachment{
+		UserID:       userID,
+		Key:          key,
+		ThumbnailKey: thumbnailKey,
+		ContentType:  img.ContentType,
+		Size:         int64(len(data)),
+		Width:        img.Width,
+		Height:       img.Height,
+	}, nil
+}
+
+// deleteAttachmentFiles удаляет файлы вложений, строки которых уже удалены.
+// Ошибки только логируются: без строки файл никто не отдаст, в худшем случае он останется лежать.
+func (r *Resolver) deleteAttachmentFiles(ctx context.Context, attachments []*repo_models.Attachment) {
+	for _, attachment := range attachments {
+		for _, key := range []string{attachment.Key, attachment.ThumbnailKey} {
+			if err := r.Blobs.Delete(ctx, key); err != nil {
+				logging.FromContext(ctx).Error("failed to delete attachment file", "attachment_id", attachment.ID, "key", key, "error", err)
+			}
+		}
+	}
+}
+
+// deletePostAttachments удаляет вложения удалённого поста вместе с файлами
+func (r *Resolver) deletePostAttachments(ctx context.Context, attachments []*repo_models.Attachment) error {
+	if len(attachments) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(attachments))
+	for _, attachment := range attachments {
+		ids = append(ids, attachment.ID)
+	}
+	if err := r.AttachmentRepo.DeleteAttachments(ctx, ids); err != nil {
+		return fmt.Errorf("failed to delete attachments: %w", err)
+	}
+	r.deleteAttachmentFiles(ctx, attachments)
+	return nil
+}
+
+// SweepAttachments раз в interval удаляет вложения, которые дольше ttl не привязаны к посту:
+// загруженные и не использованные, убранные из поста при редактировании. Работает до отмены ctx.
+func (r *Resolver) SweepAttachments(ctx context.Context, interval time.Duration, ttl time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		attachments, err := r.AttachmentRepo.DeleteStaleAttachments(ctx, time.Now().Add(-ttl))
+		if err != nil {
+			slog.Error("failed to delete stale attachments", "error", err)
+		} else if len(attachments) > 0 {
+			r.deleteAttachmentFiles(ctx, attachments)
+			slog.Info("stale attachments deleted", "count", len(attachments))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
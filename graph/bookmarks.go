@@ -0,0 +1,46 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AntonCkya/ozon_habr/graph/model"
+	"github.com/AntonCkya/ozon_habr/internal/auth"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+// bookmarkStats - число закладок на каждый пост и какие из них в закладках у текущего пользователя.
+// Без пользователя в контексте (рассылка планировщика) isBookmarked везде false.
+func (r *Resolver) bookmarkStats(ctx context.Context, postIDs []int) (map[int]int, map[int]bool, error) {
+	counts, err := r.BookmarkRepo.CountBookmarksByPostIDs(ctx, postIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to count bookmarks: %w", err)
+	}
+
+	bookmarked := make(map[int]bool)
+	if userID, ok := auth.GetUserID(ctx); ok {
+		bookmarked, err = r.BookmarkRepo.GetBookmarkedPostIDs(ctx, userID, postIDs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get bookmarks: %w", err)
+		}
+	}
+
+	return counts, bookmarked, nil
+}
+
+// getVisiblePost собирает один пост, если пользователь может его видеть
+func (r *Resolver) getVisiblePost(ctx context.Context, userID int, postID int) (*model.Post, error) {
+	post, err := r.PostRepo.GetPostByID(ctx, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+	if !canViewPost(post, userID) {
+		return nil, fmt.Errorf("failed to get post: post not found")
+	}
+
+	model_posts, err := r.postsToModel(ctx, []*repo_models.Post{post})
+	if err != nil {
+		return nil, err
+	}
+	return model_posts[0], nil
+}
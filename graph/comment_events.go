@@ -0,0 +1,77 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/AntonCkya/ozon_habr/graph/model"
+	"github.com/AntonCkya/ozon_habr/internal/metrics"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+// observeCommentSubscriptions ведёт comment_subscriptions_active для CommentHub
+func observeCommentSubscriptions(postID int, subscribers int) {
+	label := strconv.Itoa(postID)
+	if subscribers == 0 {
+		metrics.CommentSubscriptions.DeleteLabelValues(label)
+		return
+	}
+	metrics.CommentSubscriptions.WithLabelValues(label).Set(float64(subscribers))
+}
+
+// commentEvent - комментарий, отправляемый подписчикам, вместе с id события
+type commentEvent struct {
+	id      int
+	comment *model.Comment
+}
+
+func (r *Resolver) commentEventsToModel(ctx context.Context, events []*repo_models.CommentEvent) ([]commentEvent, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	var commentUsersIds []int
+	for _, event := range events {
+		commentUsersIds = append(commentUsersIds, event.Comment.UserID)
+	}
+	comment_users, err := r.UserRepo.GetUsersByIDs(ctx, commentUsersIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	result := make([]commentEvent, 0, len(events))
+	for _, event := range events {
+		comment := event.Comment
+		var ParentId *string
+		if comment.ParentID != nil {
+			parentIdValue := strconv.Itoa(*comment.ParentID)
+			ParentId = &parentIdValue
+		}
+		eventId := strconv.Itoa(event.ID)
+		result = append(result, commentEvent{
+			id: event.ID,
+			comment: &model.Comment{
+				ID:            strconv.Itoa(comment.ID),
+				Content:       comment.Content,
+				ContentFormat: model.ContentFormat(comment.ContentFormat),
+				User:          findUser(comment_users, comment.UserID),
+				ParentID:      ParentId,
+				PostID:        strconv.Itoa(comment.PostID),
+				EventID:       &eventId,
+			},
+		})
+	}
+
+	return result, nil
+}
+
+// lastReplayedID - наибольший id среди досланных событий. Живые события с id не больше него
+// считаем уже досланными, остальные отправляем, даже если они пришли не по порядку.
+func lastReplayedID(missed []commentEvent) int {
+	last := 0
+	for _, event := range missed {
+		last = max(last, event.id)
+	}
+	return last
+}
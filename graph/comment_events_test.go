@@ -0,0 +1,23 @@
+package graph
+
+import "testing"
+
+func TestLastReplayedID(t *testing.T) {
+	tests := []struct {
+		name   string
+		missed []commentEvent
+		want   int
+	}{
+		{"nothing replayed", nil, 0},
+		{"ordered", []commentEvent{{id: 3}, {id: 4}, {id: 7}}, 7},
+		{"unordered", []commentEvent{{id: 9}, {id: 4}}, 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastReplayedID(tt.missed); got != tt.want {
+				t.Errorf("lastReplayedID = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
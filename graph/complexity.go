@@ -0,0 +1,60 @@
+package graph
+
+import "github.com/AntonCkya/ozon_habr/graph/model"
+
+// SetComplexity задаёт веса списочных полей: стоимость элемента умножается на limit/first.
+// У Post.comments нет аргументов, поэтому для него берётся оценка commentsPerPost.
+func SetComplexity(c *Config, commentsPerPost int) {
+	c.Complexity.Query.Posts = func(childComplexity int, limit *int32, offset *int32, orderBy *model.PostOrder) int {
+		return listComplexity(childComplexity, limit)
+	}
+	c.Complexity.Query.PostsByUser = func(childComplexity int, limit *int32, offset *int32, userID string) int {
+		return listComplexity(childComplexity, limit)
+	}
+	c.Complexity.Query.Comments = func(childComplexity int, limit *int32, offset *int32, postID string) int {
+		return listComplexity(childComplexity, limit)
+	}
+	c.Complexity.Query.Notifications = func(childComplexity int, first *int32, after *string, unreadOnly *bool) int {
+		return listComplexity(childComplexity, first)
+	}
+	c.Complexity.Query.PostsByTag = func(childComplexity int, tag string, first *int32, after *string) int {
+		return listComplexity(childComplexity, first)
+	}
+	c.Complexity.Query.MyDrafts = func(childComplexity int, first *int32, after *string) int {
+		return listComplexity(childComplexity, first)
+	}
+	c.Complexity.Query.Bookmarks = func(childComplexity int, first *int32, after *string) int {
+		return listComplexity(childComplexity, first)
+	}
+	c.Complexity.Query.Feed = func(childComplexity int, first *int32, after *string) int {
+		return listComplexity(childComplexity, first)
+	}
+	c.Complexity.Query.Tags = func(childComplexity int, query string, first *int32) int {
+		return listComplexity(childComplexity, first)
+	}
+	c.Complexity.Post.Comments = func(childComplexity int) int {
+		return 1 + childComplexity*commentsPerPost
+	}
+	// счётчики - отдельный запрос в базу на каждого пользователя
+	c.Complexity.User.PostCount = func(childComplexity int) int {
+		return 5
+	}
+	c.Complexity.User.CommentCount = func(childComplexity int) int {
+		return 5
+	}
+	// плюс запрос totalCount
+	c.Complexity.User.Followers = func(childComplexity int, first *int32, after *string) int {
+		return 5 + listComplexity(childComplexity, first)
+	}
+	c.Complexity.User.Following = func(childComplexity int, first *int32, after *string) int {
+		return 5 + listComplexity(childComplexity, first)
+	}
+}
+
+func listComplexity(childComplexity int, limit *int32) int {
+	size := 10
+	if limit != nil && *limit > 0 {
+		size = int(*limit)
+	}
+	return 1 + childComplexity*size
+}
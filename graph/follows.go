@@ -0,0 +1,110 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/graph/model"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+// followConnection собирает User.followers (followers = true) или User.following
+func (r *Resolver) followConnection(ctx context.Context, obj *model.User, first *int32, after *string, followers bool) (*model.UserConnection, error) {
+	userID, err := strconv.Atoi(obj.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert user id to int: %w", err)
+	}
+
+	if *first <= 0 {
+		return nil, fmt.Errorf("first must be positive")
+	}
+
+	var afterId int
+	if after != nil {
+		afterId, err = strconv.Atoi(*after)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert cursor to int: %w", err)
+		}
+	}
+
+	var follows []*repo_models.Follow
+	var total int
+	// берём на один больше, чтобы узнать, есть ли следующая страница
+	if followers {
+		follows, err = r.FollowRepo.GetFollowers(ctx, userID, int(*first)+1, afterId)
+		if err == nil {
+			total, err = r.FollowRepo.CountFollowers(ctx, userID)
+		}
+	} else {
+		follows, err = r.FollowRepo.GetFollowing(ctx, userID, int(*first)+1, afterId)
+		if err == nil {
+			total, err = r.FollowRepo.CountFollowing(ctx, userID)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get follows: %w", err)
+	}
+	hasNextPage := len(follows) > int(*first)
+	if hasNextPage {
+		follows = follows[:*first]
+	}
+
+	var userIds []int
+	for _, follow := range follows {
+		if followers {
+			userIds = append(userIds, follow.FollowerID)
+		} else {
+			userIds = append(userIds, follow.UserID)
+		}
+	}
+	users, err := r.UserRepo.GetUsersByIDs(ctx, userIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	model_users := make([]*model.User, 0, len(userIds))
+	for _, id := range userIds {
+		model_users = append(model_users, findUser(users, id))
+	}
+
+	// курсор - id подписки: список упорядочен по времени подписки
+	pageInfo := model.PageInfo{HasNextPage: hasNextPage}
+	if len(follows) > 0 {
+		endCursor := strconv.Itoa(follows[len(follows)-1].ID)
+		pageInfo.EndCursor = &endCursor
+	}
+
+	return &model.UserConnection{
+		Nodes:      model_users,
+		PageInfo:   &pageInfo,
+		TotalCount: int32(total),
+	}, nil
+}
+
+// курсор ленты - "<время публикации в наносекундах>:<id>", см. repo_models.FeedCursor
+func feedCursor(post *repo_models.Post) string {
+	var publishAt int64
+	if post.PublishAt != nil {
+		publishAt = post.PublishAt.UnixNano()
+	}
+	return strconv.FormatInt(publishAt, 10) + ":" + strconv.Itoa(post.ID)
+}
+
+func parseFeedCursor(cursor string) (*repo_models.FeedCursor, error) {
+	publishAt, id, ok := strings.Cut(cursor, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid feed cursor")
+	}
+	nanos, err := strconv.ParseInt(publishAt, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feed cursor: %w", err)
+	}
+	postID, err := strconv.Atoi(id)
+	if err != nil || postID <= 0 {
+		return nil, fmt.Errorf("invalid feed cursor")
+	}
+	return &repo_models.FeedCursor{PublishAt: time.Unix(0, nanos), ID: postID}, nil
+}
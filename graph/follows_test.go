@@ -0,0 +1,27 @@
+package graph
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+func TestFeedCursor(t *testing.T) {
+	publishAt := time.Date(2026, 3, 1, 12, 0, 0, 123456000, time.UTC)
+	cursor := feedCursor(&repo_models.Post{ID: 42, PublishAt: &publishAt})
+
+	got, err := parseFeedCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 42 || !got.PublishAt.Equal(publishAt) {
+		t.Errorf("parseFeedCursor(%q) = %+v, want {%v 42}", cursor, got, publishAt)
+	}
+
+	for _, invalid := range []string{"", "42", "abc:1", "1:abc", "1:0", "1:-5"} {
+		if _, err := parseFeedCursor(invalid); err == nil {
+			t.Errorf("parseFeedCursor(%q) returned no error", invalid)
+		}
+	}
+}
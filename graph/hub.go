@@ -0,0 +1,68 @@
+package graph
+
+import "sync"
+
+// hub рассылает события подписчикам, сгруппированным по ключу (например, id поста).
+// Публикация не блокируется: если буфер подписчика переполнен, событие ему не отправляется.
+type hub[T any] struct {
+	mu          sync.Mutex
+	subscribers map[int]map[chan T]struct{}
+	bufferSize  int
+
+	// observe вызывается под блокировкой при каждом изменении числа подписчиков ключа,
+	// поэтому видит изменения в том же порядке, в каком они происходят
+	observe func(key int, subscribers int)
+}
+
+func newHub[T any](bufferSize int) *hub[T] {
+	return &hub[T]{
+		subscribers: make(map[int]map[chan T]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+func (h *hub[T]) withObserver(observe func(key int, subscribers int)) *hub[T] {
+	h.observe = observe
+	return h
+}
+
+func (h *hub[T]) Subscribe(key int) chan T {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan T, h.bufferSize)
+	if _, ok := h.subscribers[key]; !ok {
+		h.subscribers[key] = make(map[chan T]struct{})
+	}
+	h.subscribers[key][ch] = struct{}{}
+	if h.observe != nil {
+		h.observe(key, len(h.subscribers[key]))
+	}
+	return ch
+}
+
+func (h *hub[T]) Unsubscribe(key int, ch chan T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.subscribers[key], ch)
+	count := len(h.subscribers[key])
+	if count == 0 {
+		delete(h.subscribers, key)
+	}
+	if h.observe != nil {
+		h.observe(key, count)
+	}
+}
+
+func (h *hub[T]) Publish(key int, event T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers[key] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
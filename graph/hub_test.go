@@ -0,0 +1,29 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHubObserver(t *testing.T) {
+	type change struct{ key, subscribers int }
+	var changes []change
+	h := newHub[int](1).withObserver(func(key int, subscribers int) {
+		changes = append(changes, change{key, subscribers})
+	})
+
+	a := h.Subscribe(1)
+	b := h.Subscribe(1)
+	c := h.Subscribe(2)
+	h.Unsubscribe(1, a)
+	h.Unsubscribe(1, b)
+	h.Unsubscribe(2, c)
+
+	want := []change{{1, 1}, {1, 2}, {2, 1}, {1, 1}, {1, 0}, {2, 0}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+	if len(h.subscribers) != 0 {
+		t.Errorf("subscribers left: %v", h.subscribers)
+	}
+}
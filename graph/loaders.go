@@ -0,0 +1,85 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// сколько loader ждёт остальные ключи, прежде чем сходить в хранилище
+const loaderWait = time.Millisecond
+
+type loadersKey struct{}
+
+// loaders живут одну операцию: gqlgen резолвит поля элементов списка параллельно,
+// и postCount/commentCount всех пользователей в ответе собираются в один запрос
+type loaders struct {
+	postCounts    *countLoader
+	commentCounts *countLoader
+}
+
+func (r *Resolver) newLoaders() *loaders {
+	return &loaders{
+		postCounts:    &countLoader{fetch: r.PostRepo.CountPostsByUserIDs},
+		commentCounts: &countLoader{fetch: r.CommentRepo.CountCommentsByUserIDs},
+	}
+}
+
+// WithLoaders кладёт в контекст операции свежие loaders, подключается через srv.AroundOperations
+func (r *Resolver) WithLoaders(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	return next(context.WithValue(ctx, loadersKey{}, r.newLoaders()))
+}
+
+// loaders операции, без WithLoaders (вызов мимо сервера) каждый раз новые, то есть без батчинга
+func (r *Resolver) loaders(ctx context.Context) *loaders {
+	if l, ok := ctx.Value(loadersKey{}).(*loaders); ok {
+		return l
+	}
+	return r.newLoaders()
+}
+
+type countLoader struct {
+	fetch func(ctx context.Context, ids []int) (map[int]int, error)
+
+	mu    sync.Mutex
+	batch *countBatch
+}
+
+type countBatch struct {
+	ids    []int
+	done   chan struct{}
+	counts map[int]int
+	err    error
+}
+
+func (l *countLoader) load(ctx context.Context, id int) (int, error) {
+	l.mu.Lock()
+	if l.batch == nil {
+		l.batch = &countBatch{done: make(chan struct{})}
+		go l.run(ctx, l.batch)
+	}
+	batch := l.batch
+	batch.ids = append(batch.ids, id)
+	l.mu.Unlock()
+
+	<-batch.done
+	if batch.err != nil {
+		return 0, batch.err
+	}
+	return batch.counts[id], nil
+}
+
+func (l *countLoader) run(ctx context.Context, batch *countBatch) {
+	time.Sleep(loaderWait)
+
+	// дальше новые ключи идут уже в следующую пачку
+	l.mu.Lock()
+	l.batch = nil
+	ids := batch.ids
+	l.mu.Unlock()
+
+	batch.counts, batch.err = l.fetch(ctx, ids)
+	close(batch.done)
+}
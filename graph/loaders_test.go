@@ -0,0 +1,76 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestCountLoaderBatchesConcurrentLoads(t *testing.T) {
+	var mu sync.Mutex
+	var calls [][]int
+	l := &countLoader{fetch: func(ctx context.Context, ids []int) (map[int]int, error) {
+		mu.Lock()
+		calls = append(calls, append([]int(nil), ids...))
+		mu.Unlock()
+
+		counts := make(map[int]int)
+		for _, id := range ids {
+			if id != 3 {
+				counts[id] = id * 10
+			}
+		}
+		return counts, nil
+	}}
+
+	ids := []int{1, 2, 3, 4}
+	got := make([]int, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			count, err := l.load(context.Background(), id)
+			if err != nil {
+				t.Errorf("load(%d): %v", id, err)
+			}
+			got[i] = count
+		}()
+	}
+	wg.Wait()
+
+	if len(calls) != 1 {
+		t.Fatalf("fetch called %d times, want 1: %v", len(calls), calls)
+	}
+	sort.Ints(calls[0])
+	if len(calls[0]) != len(ids) {
+		t.Errorf("fetched ids = %v, want %v", calls[0], ids)
+	}
+	want := []int{10, 20, 0, 40}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("count for %d = %d, want %d", ids[i], got[i], want[i])
+		}
+	}
+
+	// после завершения пачки следующий вызов идёт новым запросом
+	if _, err := l.load(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 {
+		t.Errorf("fetch called %d times, want 2", len(calls))
+	}
+}
+
+func TestCountLoaderError(t *testing.T) {
+	want := errors.New("db is down")
+	l := &countLoader{fetch: func(ctx context.Context, ids []int) (map[int]int, error) {
+		return nil, want
+	}}
+
+	if _, err := l.load(context.Background(), 1); !errors.Is(err, want) {
+		t.Errorf("load error = %v, want %v", err, want)
+	}
+}
@@ -2,36 +2,104 @@
 
 package model
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type Attachment struct {
+	ID           string `json:"id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+	ContentType  string `json:"contentType"`
+	Size         int32  `json:"size"`
+	Width        int32  `json:"width"`
+	Height       int32  `json:"height"`
+}
+
 type Comment struct {
-	ID       string  `json:"id"`
-	Content  string  `json:"content"`
-	User     *User   `json:"user"`
-	ParentID *string `json:"parentId,omitempty"`
-	PostID   string  `json:"postId"`
+	ID            string        `json:"id"`
+	Content       string        `json:"content"`
+	ContentFormat ContentFormat `json:"contentFormat"`
+	ContentHTML   string        `json:"contentHtml"`
+	User          *User         `json:"user"`
+	ParentID      *string       `json:"parentId,omitempty"`
+	PostID        string        `json:"postId"`
+	EventID       *string       `json:"eventId,omitempty"`
 }
 
 type CommentInput struct {
-	PostID   string  `json:"postId"`
-	ParentID *string `json:"parentId,omitempty"`
-	Content  string  `json:"content"`
+	PostID        string         `json:"postId"`
+	ParentID      *string        `json:"parentId,omitempty"`
+	Content       string         `json:"content"`
+	ContentFormat *ContentFormat `json:"contentFormat,omitempty"`
 }
 
 type Mutation struct {
 }
 
+type Notification struct {
+	ID        string           `json:"id"`
+	Type      NotificationType `json:"type"`
+	Actor     *User            `json:"actor"`
+	PostID    string           `json:"postId"`
+	CommentID string           `json:"commentId"`
+	Read      bool             `json:"read"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+type NotificationConnection struct {
+	Nodes    []*Notification `json:"nodes"`
+	PageInfo *PageInfo       `json:"pageInfo"`
+}
+
+type PageInfo struct {
+	EndCursor   *string `json:"endCursor,omitempty"`
+	HasNextPage bool    `json:"hasNextPage"`
+}
+
 type Post struct {
-	ID          string     `json:"id"`
-	Title       string     `json:"title"`
-	Content     string     `json:"content"`
-	User        *User      `json:"user"`
-	Commentable bool       `json:"commentable"`
-	Comments    []*Comment `json:"comments"`
+	ID            string        `json:"id"`
+	Title         string        `json:"title"`
+	Content       string        `json:"content"`
+	ContentFormat ContentFormat `json:"contentFormat"`
+	ContentHTML   string        `json:"contentHtml"`
+	User          *User         `json:"user"`
+	Commentable   bool          `json:"commentable"`
+	Comments      []*Comment    `json:"comments"`
+	Tags          []string      `json:"tags"`
+	Status        PostStatus    `json:"status"`
+	PublishAt     *time.Time    `json:"publishAt,omitempty"`
+	Attachments   []*Attachment `json:"attachments"`
+	IsBookmarked  bool          `json:"isBookmarked"`
+	BookmarkCount int32         `json:"bookmarkCount"`
+	CommentCount  int32         `json:"commentCount"`
+	LastCommentAt *time.Time    `json:"lastCommentAt,omitempty"`
+}
+
+type PostConnection struct {
+	Nodes    []*Post   `json:"nodes"`
+	PageInfo *PageInfo `json:"pageInfo"`
 }
 
 type PostInput struct {
-	Title       string `json:"title"`
-	Content     string `json:"content"`
-	Commentable bool   `json:"commentable"`
+	Title         string         `json:"title"`
+	Content       string         `json:"content"`
+	ContentFormat *ContentFormat `json:"contentFormat,omitempty"`
+	Commentable   bool           `json:"commentable"`
+	Tags          []string       `json:"tags,omitempty"`
+	Status        *PostStatus    `json:"status,omitempty"`
+	PublishAt     *time.Time     `json:"publishAt,omitempty"`
+	AttachmentIds []string       `json:"attachmentIds,omitempty"`
+}
+
+type ProfileInput struct {
+	DisplayName *string `json:"displayName,omitempty"`
+	Bio         *string `json:"bio,omitempty"`
+	AvatarURL   *string `json:"avatarUrl,omitempty"`
 }
 
 type Query struct {
@@ -40,7 +108,254 @@ type Query struct {
 type Subscription struct {
 }
 
+type Tag struct {
+	Name          string `json:"name"`
+	PostCount     int32  `json:"postCount"`
+	FollowerCount int32  `json:"followerCount"`
+	IsFollowed    bool   `json:"isFollowed"`
+}
+
 type User struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
+	ID           string          `json:"id"`
+	Username     string          `json:"username"`
+	DisplayName  *string         `json:"displayName,omitempty"`
+	Bio          *string         `json:"bio,omitempty"`
+	AvatarURL    *string         `json:"avatarUrl,omitempty"`
+	RegisteredAt time.Time       `json:"registeredAt"`
+	PostCount    int32           `json:"postCount"`
+	CommentCount int32           `json:"commentCount"`
+	Followers    *UserConnection `json:"followers"`
+	Following    *UserConnection `json:"following"`
+}
+
+type UserConnection struct {
+	Nodes      []*User   `json:"nodes"`
+	PageInfo   *PageInfo `json:"pageInfo"`
+	TotalCount int32     `json:"totalCount"`
+}
+
+type ContentFormat string
+
+const (
+	ContentFormatPlain    ContentFormat = "PLAIN"
+	ContentFormatMarkdown ContentFormat = "MARKDOWN"
+)
+
+var AllContentFormat = []ContentFormat{
+	ContentFormatPlain,
+	ContentFormatMarkdown,
+}
+
+func (e ContentFormat) IsValid() bool {
+	switch e {
+	case ContentFormatPlain, ContentFormatMarkdown:
+		return true
+	}
+	return false
+}
+
+func (e ContentFormat) String() string {
+	return string(e)
+}
+
+func (e *ContentFormat) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ContentFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ContentFormat", str)
+	}
+	return nil
+}
+
+func (e ContentFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ContentFormat) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ContentFormat) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type NotificationType string
+
+const (
+	NotificationTypeCommentReply NotificationType = "COMMENT_REPLY"
+	NotificationTypePostComment  NotificationType = "POST_COMMENT"
+	NotificationTypeMention      NotificationType = "MENTION"
+)
+
+var AllNotificationType = []NotificationType{
+	NotificationTypeCommentReply,
+	NotificationTypePostComment,
+	NotificationTypeMention,
+}
+
+func (e NotificationType) IsValid() bool {
+	switch e {
+	case NotificationTypeCommentReply, NotificationTypePostComment, NotificationTypeMention:
+		return true
+	}
+	return false
+}
+
+func (e NotificationType) String() string {
+	return string(e)
+}
+
+func (e *NotificationType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = NotificationType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid NotificationType", str)
+	}
+	return nil
+}
+
+func (e NotificationType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *NotificationType) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e NotificationType) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PostOrder string
+
+const (
+	PostOrderMostDiscussed     PostOrder = "MOST_DISCUSSED"
+	PostOrderRecentlyDiscussed PostOrder = "RECENTLY_DISCUSSED"
+)
+
+var AllPostOrder = []PostOrder{
+	PostOrderMostDiscussed,
+	PostOrderRecentlyDiscussed,
+}
+
+func (e PostOrder) IsValid() bool {
+	switch e {
+	case PostOrderMostDiscussed, PostOrderRecentlyDiscussed:
+		return true
+	}
+	return false
+}
+
+func (e PostOrder) String() string {
+	return string(e)
+}
+
+func (e *PostOrder) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostOrder(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostOrder", str)
+	}
+	return nil
+}
+
+func (e PostOrder) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PostOrder) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PostOrder) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PostStatus string
+
+const (
+	PostStatusDraft     PostStatus = "DRAFT"
+	PostStatusScheduled PostStatus = "SCHEDULED"
+	PostStatusPublished PostStatus = "PUBLISHED"
+	PostStatusArchived  PostStatus = "ARCHIVED"
+)
+
+var AllPostStatus = []PostStatus{
+	PostStatusDraft,
+	PostStatusScheduled,
+	PostStatusPublished,
+	PostStatusArchived,
+}
+
+func (e PostStatus) IsValid() bool {
+	switch e {
+	case PostStatusDraft, PostStatusScheduled, PostStatusPublished, PostStatusArchived:
+		return true
+	}
+	return false
+}
+
+func (e PostStatus) String() string {
+	return string(e)
+}
+
+func (e *PostStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostStatus", str)
+	}
+	return nil
+}
+
+func (e PostStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PostStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PostStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,79 @@
+package graph
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+
+	"github.com/AntonCkya/ozon_habr/graph/model"
+	"github.com/AntonCkya/ozon_habr/internal/logging"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+// перед @ должно быть начало текста или не буква, иначе упоминанием считался бы домен в e-mail
+var mentionRegexp = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])@([\p{L}\p{N}_]+)`)
+
+// не больше стольких упоминаний из одного комментария, чтобы нельзя было заспамить всех
+const maxMentionsPerComment = 10
+
+// notifyAboutComment создаёт уведомления об ответе на комментарий, комментарии к посту и упоминаниях.
+// Ошибки только логируются: комментарий уже создан, и из-за уведомлений его не откатываем.
+func (r *Resolver) notifyAboutComment(ctx context.Context, post *repo_models.Post, comment *repo_models.Comment, actor *model.User) {
+	notified := map[int]bool{comment.UserID: true}
+
+	notify := func(userID int, notificationType string) {
+		if notified[userID] {
+			return
+		}
+		notified[userID] = true
+
+		notification, err := r.NotificationRepo.CreateNotification(ctx, userID, comment.UserID, notificationType, comment.PostID, comment.ID)
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to create notification", "recipient_id", userID, "error", err)
+			return
+		}
+		r.NotificationHub.Publish(userID, notificationToModel(notification, actor))
+	}
+
+	if comment.ParentID != nil {
+		parent, err := r.CommentRepo.GetCommentByID(ctx, *comment.ParentID)
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to get parent comment", "comment_id", *comment.ParentID, "error", err)
+		} else {
+			notify(parent.UserID, repo_models.NotificationCommentReply)
+		}
+	}
+
+	notify(post.UserID, repo_models.NotificationPostComment)
+
+	mentions := mentionRegexp.FindAllStringSubmatch(comment.Content, -1)
+	seen := make(map[string]bool)
+	for _, mention := range mentions {
+		username := mention[1]
+		if seen[username] {
+			continue
+		}
+		seen[username] = true
+		if len(seen) > maxMentionsPerComment {
+			break
+		}
+
+		user, err := r.UserRepo.GetUserByUsername(ctx, username)
+		if err != nil {
+			continue
+		}
+		notify(user.ID, repo_models.NotificationMention)
+	}
+}
+
+func notificationToModel(notification *repo_models.Notification, actor *model.User) *model.Notification {
+	return &model.Notification{
+		ID:        strconv.Itoa(notification.ID),
+		Type:      model.NotificationType(notification.Type),
+		Actor:     actor,
+		PostID:    strconv.Itoa(notification.PostID),
+		CommentID: strconv.Itoa(notification.CommentID),
+		Read:      notification.Read,
+		CreatedAt: notification.CreatedAt,
+	}
+}
@@ -0,0 +1,34 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMentionRegexp(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"start of text", "@alice hi", []string{"alice"}},
+		{"after space", "hi @alice", []string{"alice"}},
+		{"after punctuation", "(@alice), @bob!", []string{"alice", "bob"}},
+		{"cyrillic", "привет @вася_1", []string{"вася_1"}},
+		{"email domain", "write to bob@example.com", nil},
+		{"glued mentions", "@alice@bob", []string{"alice"}},
+		{"no mentions", "just text", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, match := range mentionRegexp.FindAllStringSubmatch(tt.content, -1) {
+				got = append(got, match[1])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mentions in %q = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/graph/model"
+	"github.com/AntonCkya/ozon_habr/internal/logging"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+// все опубликованные посты рассылаются подписчикам postPublished по одному ключу
+const allPostsKey = 0
+
+// postStatus вычисляет статус и время публикации из PostInput.
+// prev - текущая версия поста при обновлении, nil при создании.
+func postStatus(input model.PostInput, prev *repo_models.Post, now time.Time) (string, *time.Time, error) {
+	var prevStatus string
+	var prevPublishAt *time.Time
+	if prev != nil {
+		prevStatus = prev.Status
+		prevPublishAt = prev.PublishAt
+	}
+	wasPublished := prevStatus == repo_models.PostStatusPublished || prevStatus == repo_models.PostStatusArchived
+
+	status := repo_models.PostStatusPublished
+	if prev != nil {
+		status = prev.Status
+	}
+	if input.Status != nil {
+		status = string(*input.Status)
+	}
+
+	if input.PublishAt != nil && status != repo_models.PostStatusScheduled {
+		return "", nil, fmt.Errorf("publishAt can be set only for scheduled posts")
+	}
+
+	switch status {
+	case repo_models.PostStatusDraft:
+		if wasPublished {
+			return "", nil, fmt.Errorf("published post can't become a draft")
+		}
+		return status, nil, nil
+	case repo_models.PostStatusScheduled:
+		if wasPublished {
+			return "", nil, fmt.Errorf("published post can't be scheduled")
+		}
+		if input.PublishAt == nil {
+			// запланированный пост можно редактировать, не передавая время заново
+			if prevStatus != repo_models.PostStatusScheduled || prevPublishAt == nil {
+				return "", nil, fmt.Errorf("publishAt is required for scheduled posts")
+			}
+			return status, prevPublishAt, nil
+		}
+		if !input.PublishAt.After(now) {
+			return "", nil, fmt.Errorf("publishAt must be in the future")
+		}
+		return status, input.PublishAt, nil
+	case repo_models.PostStatusPublished:
+		if wasPublished {
+			return status, prevPublishAt, nil
+		}
+		return status, &now, nil
+	case repo_models.PostStatusArchived:
+		if !wasPublished {
+			return "", nil, fmt.Errorf("only published posts can be archived")
+		}
+		return status, prevPublishAt, nil
+	}
+
+	return "", nil, fmt.Errorf("unknown post status %q", status)
+}
+
+// canViewPost: черновики и запланированные посты видит только автор
+func canViewPost(post *repo_models.Post, userID int) bool {
+	switch post.Status {
+	case repo_models.PostStatusPublished, repo_models.PostStatusArchived:
+		return true
+	}
+	return post.UserID == userID
+}
+
+// discardPost удаляет только что созданный пост, если не удалось сохранить его теги или вложения.
+// Удаляем и после отмены запроса: ошибка могла быть как раз из-за неё.
+func (r *Resolver) discardPost(ctx context.Context, postID int) {
+	if err := r.PostRepo.DeletePost(context.WithoutCancel(ctx), postID); err != nil {
+		logging.FromContext(ctx).Error("failed to delete incomplete post", "post_id", postID, "error", err)
+	}
+}
+
+// postsToModel собирает страницу постов: авторы, комментарии, теги, вложения и закладки загружаются пачкой
+func (r *Resolver) postsToModel(ctx context.Context, posts []*repo_models.Post) ([]*model.Post, error) {
+	var userIds []int
+	var postIds []int
+	for _, post := range posts {
+		userIds = append(userIds, post.UserID)
+		postIds = append(postIds, post.ID)
+	}
+	users, err := r.UserRepo.GetUsersByIDs(ctx, userIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	comments, err := r.CommentRepo.GetCommentsByPostIDs(ctx, postIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+
+	var commentUsersIds []int
+	for _, comment := range comments {
+		commentUsersIds = append(commentUsersIds, comment.UserID)
+	}
+	comment_users, err := r.UserRepo.GetUsersByIDs(ctx, commentUsersIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	tags, err := r.TagRepo.GetTagsByPostIDs(ctx, postIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	attachments, err := r.AttachmentRepo.GetAttachmentsByPostIDs(ctx, postIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+
+	bookmark_counts, bookmarked, err := r.bookmarkStats(ctx, postIds)
+	if err != nil {
+		return nil, err
+	}
+
+	var model_posts []*model.Post
+	for _, post := range posts {
+		var model_comments []*model.Comment
+		for _, comment := range comments {
+			if comment.PostID != post.ID {
+				continue
+			}
+			var ParentId *string
+			if comment.ParentID != nil {
+				parentIdValue := strconv.Itoa(*comment.ParentID)
+				ParentId = &parentIdValue
+			}
+			model_comments = append(model_comments, &model.Comment{
+				ID:            strconv.Itoa(comment.ID),
+				Content:       comment.Content,
+				ContentFormat: model.ContentFormat(comment.ContentFormat),
+				User:          findUser(comment_users, comment.UserID),
+				ParentID:      ParentId,
+				PostID:        strconv.Itoa(comment.PostID),
+			})
+		}
+
+		model_posts = append(model_posts, &model.Post{
+			ID:            strconv.Itoa(post.ID),
+			Title:         post.Title,
+			Content:       post.Content,
+			ContentFormat: model.ContentFormat(post.ContentFormat),
+			User:          findUser(users, post.UserID),
+			Commentable:   post.Commentable,
+			Comments:      model_comments,
+			Tags:          tags[post.ID],
+			Status:        model.PostStatus(post.Status),
+			PublishAt:     post.PublishAt,
+			Attachments:   r.attachmentsToModel(attachments[post.ID]),
+			IsBookmarked:  bookmarked[post.ID],
+			BookmarkCount: int32(bookmark_counts[post.ID]),
+			CommentCount:  int32(post.CommentCount),
+			LastCommentAt: post.LastCommentAt,
+		})
+	}
+
+	return model_posts, nil
+}
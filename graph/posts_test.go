@@ -0,0 +1,140 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/graph/model"
+	"github.com/AntonCkya/ozon_habr/internal/auth"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+func TestPostStatus(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	later := now.Add(2 * time.Hour)
+
+	status := func(s model.PostStatus) *model.PostStatus { return &s }
+	post := func(s string, publishAt *time.Time) *repo_models.Post {
+		return &repo_models.Post{Status: s, PublishAt: publishAt}
+	}
+
+	tests := []struct {
+		name          string
+		input         model.PostInput
+		prev          *repo_models.Post
+		wantStatus    string
+		wantPublishAt *time.Time
+		wantErr       bool
+	}{
+		{"create published by default", model.PostInput{}, nil, repo_models.PostStatusPublished, &now, false},
+		{"create draft", model.PostInput{Status: status(model.PostStatusDraft)}, nil, repo_models.PostStatusDraft, nil, false},
+		{"create scheduled", model.PostInput{Status: status(model.PostStatusScheduled), PublishAt: &future}, nil, repo_models.PostStatusScheduled, &future, false},
+		{"scheduled without time", model.PostInput{Status: status(model.PostStatusScheduled)}, nil, "", nil, true},
+		{"scheduled in the past", model.PostInput{Status: status(model.PostStatusScheduled), PublishAt: &past}, nil, "", nil, true},
+		{"scheduled right now", model.PostInput{Status: status(model.PostStatusScheduled), PublishAt: &now}, nil, "", nil, true},
+		{"publishAt without scheduling", model.PostInput{PublishAt: &future}, nil, "", nil, true},
+		{"create archived", model.PostInput{Status: status(model.PostStatusArchived)}, nil, "", nil, true},
+
+		{"keep status on update", model.PostInput{}, post(repo_models.PostStatusDraft, nil), repo_models.PostStatusDraft, nil, false},
+		{"publish draft", model.PostInput{Status: status(model.PostStatusPublished)}, post(repo_models.PostStatusDraft, nil), repo_models.PostStatusPublished, &now, false},
+		{"draft to scheduled", model.PostInput{Status: status(model.PostStatusScheduled), PublishAt: &future}, post(repo_models.PostStatusDraft, nil), repo_models.PostStatusScheduled, &future, false},
+		{"edit scheduled keeps time", model.PostInput{}, post(repo_models.PostStatusScheduled, &future), repo_models.PostStatusScheduled, &future, false},
+		{"reschedule", model.PostInput{PublishAt: &later}, post(repo_models.PostStatusScheduled, &future), repo_models.PostStatusScheduled, &later, false},
+		{"scheduled back to draft", model.PostInput{Status: status(model.PostStatusDraft)}, post(repo_models.PostStatusScheduled, &future), repo_models.PostStatusDraft, nil, false},
+		{"publish scheduled now", model.PostInput{Status: status(model.PostStatusPublished)}, post(repo_models.PostStatusScheduled, &future), repo_models.PostStatusPublished, &now, false},
+
+		{"edit published keeps time", model.PostInput{}, post(repo_models.PostStatusPublished, &past), repo_models.PostStatusPublished, &past, false},
+		{"archive published", model.PostInput{Status: status(model.PostStatusArchived)}, post(repo_models.PostStatusPublished, &past), repo_models.PostStatusArchived, &past, false},
+		{"unarchive", model.PostInput{Status: status(model.PostStatusPublished)}, post(repo_models.PostStatusArchived, &past), repo_models.PostStatusPublished, &past, false},
+		{"published to draft", model.PostInput{Status: status(model.PostStatusDraft)}, post(repo_models.PostStatusPublished, &past), "", nil, true},
+		{"published to scheduled", model.PostInput{Status: status(model.PostStatusScheduled), PublishAt: &future}, post(repo_models.PostStatusPublished, &past), "", nil, true},
+		{"archive draft", model.PostInput{Status: status(model.PostStatusArchived)}, post(repo_models.PostStatusDraft, nil), "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStatus, gotPublishAt, err := postStatus(tt.input, tt.prev, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("postStatus error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotStatus != tt.wantStatus {
+				t.Errorf("status = %q, want %q", gotStatus, tt.wantStatus)
+			}
+			switch {
+			case gotPublishAt == nil && tt.wantPublishAt == nil:
+			case gotPublishAt == nil || tt.wantPublishAt == nil || !gotPublishAt.Equal(*tt.wantPublishAt):
+				t.Errorf("publishAt = %v, want %v", gotPublishAt, tt.wantPublishAt)
+			}
+		})
+	}
+}
+
+// failingTags - хранилище тегов, в которое не удаётся записать теги поста
+type failingTags struct {
+	TagRepoInterface
+}
+
+func (failingTags) SetPostTags(ctx context.Context, postID int, tags []string) error {
+	return errors.New("tags are unavailable")
+}
+
+func TestPostTagsFailure(t *testing.T) {
+	r := NewMemResolver()
+	user, err := r.UserRepo.CreateUser(context.Background(), "tags_failure", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := auth.WithUserID(context.Background(), user.ID)
+	mutation := &mutationResolver{r}
+
+	post, err := mutation.CreatePost(ctx, model.PostInput{Title: "title", Content: "text", Commentable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CommentRepo.CreateComment(ctx, "hi", repo_models.ContentFormatPlain, user.ID, mustAtoi(t, post.ID), -1); err != nil {
+		t.Fatal(err)
+	}
+
+	r.TagRepo = failingTags{r.TagRepo}
+
+	// при создании пост без тегов не остаётся
+	if _, err := mutation.CreatePost(ctx, model.PostInput{Title: "new", Content: "text", Commentable: true, Tags: []string{"go"}}); err == nil {
+		t.Error("CreatePost succeeded, want error")
+	}
+	posts, err := r.PostRepo.GetPostsByUserId(ctx, 10, 0, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Errorf("posts after failed create = %d, want 1", len(posts))
+	}
+
+	// при редактировании существующий пост и его комментарии остаются
+	if _, err := mutation.UpdatePost(ctx, post.ID, model.PostInput{Title: "edited", Content: "text", Commentable: true, Tags: []string{"go"}}); err == nil {
+		t.Error("UpdatePost succeeded, want error")
+	}
+	if _, err := r.PostRepo.GetPostByID(ctx, mustAtoi(t, post.ID)); err != nil {
+		t.Errorf("post was deleted after failed update: %v", err)
+	}
+	comments, err := r.CommentRepo.GetCommentsByPostID(ctx, mustAtoi(t, post.ID), 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 {
+		t.Errorf("comments after failed update = %d, want 1", len(comments))
+	}
+}
+
+func mustAtoi(t *testing.T, s string) int {
+	t.Helper()
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -2,11 +2,14 @@ package graph
 
 import (
 	"context"
-	"database/sql"
+	"time"
 
+	"github.com/AntonCkya/ozon_habr/graph/model"
+	"github.com/AntonCkya/ozon_habr/internal/markdown"
 	"github.com/AntonCkya/ozon_habr/internal/mem_repository"
 	"github.com/AntonCkya/ozon_habr/internal/pg_repository"
 	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+	"github.com/AntonCkya/ozon_habr/internal/storage"
 )
 
 // This file will not be regenerated automatically.
@@ -18,45 +21,164 @@ type UserRepoInterface interface {
 	GetUserByID(ctx context.Context, id int) (*repo_models.User, error)
 	GetUserByUsername(ctx context.Context, username string) (*repo_models.User, error)
 	GetUsersByIDs(ctx context.Context, ids []int) ([]*repo_models.User, error)
+	UpdateProfile(ctx context.Context, id int, displayName string, bio string, avatarURL string) (*repo_models.User, error)
+	UpdatePassword(ctx context.Context, id int, password string) (*repo_models.User, error)
+	UpdateUsername(ctx context.Context, id int, username string) (*repo_models.User, error)
+	AnonymizeUser(ctx context.Context, id int) error
+	DeleteUser(ctx context.Context, id int) error
 }
 
 type PostRepoInterface interface {
-	CreatePost(ctx context.Context, title string, content string, userID int, commentable bool) (*repo_models.Post, error)
+	CreatePost(ctx context.Context, title string, content string, contentFormat string, userID int, commentable bool, status string, publishAt *time.Time) (*repo_models.Post, error)
 	DeletePost(ctx context.Context, id int) error
 	GetPostByID(ctx context.Context, id int) (*repo_models.Post, error)
-	GetPosts(ctx context.Context, limit int, offset int) ([]*repo_models.Post, error)
+	GetPosts(ctx context.Context, limit int, offset int, order string) ([]*repo_models.Post, error)
 	GetPostsByUserId(ctx context.Context, limit int, offset int, userId int) ([]*repo_models.Post, error)
-	UpdatePost(ctx context.Context, id int, title string, content string, userID int, commentable bool) (*repo_models.Post, error)
+	CountPostsByUserIDs(ctx context.Context, userIDs []int) (map[int]int, error)
+	UpdatePost(ctx context.Context, id int, title string, content string, contentFormat string, userID int, commentable bool, status string, publishAt *time.Time) (*repo_models.Post, error)
+	GetDraftsByUserId(ctx context.Context, userId int, limit int, afterID int) ([]*repo_models.Post, error)
+	PublishDuePosts(ctx context.Context, now time.Time) ([]*repo_models.Post, error)
 }
 
 type CommentRepoInterface interface {
-	CreateComment(ctx context.Context, content string, userID int, postID int, parentID int) (*repo_models.Comment, error)
+	CreateComment(ctx context.Context, content string, contentFormat string, userID int, postID int, parentID int) (*repo_models.Comment, error)
 	DeleteComment(ctx context.Context, id int) error
 	GetCommentByID(ctx context.Context, id int) (*repo_models.Comment, error)
 	GetCommentsByPostID(ctx context.Context, postID int, limit int, offset int) ([]*repo_models.Comment, error)
 	GetCommentsByPostIDs(ctx context.Context, postIDs []int) ([]*repo_models.Comment, error)
 	GetReplies(ctx context.Context, parentID int) ([]*repo_models.Comment, error)
-	UpdateComment(ctx context.Context, id int, content string) (*repo_models.Comment, error)
+	CountCommentsByUserIDs(ctx context.Context, userIDs []int) (map[int]int, error)
+	UpdateComment(ctx context.Context, id int, content string, contentFormat string) (*repo_models.Comment, error)
 }
 
+// UserDataDeleter реализуют хранилища, которым при удалении аккаунта нужно почистить
+// данные пользователя самим: mem-хранилища и кэши. В pg всё удаляет каскад внешних ключей.
+type UserDataDeleter interface {
+	DeleteUserData(ctx context.Context, userID int) error
+}
+
+type TagRepoInterface interface {
+	SetPostTags(ctx context.Context, postID int, tags []string) error
+	GetTagsByPostIDs(ctx context.Context, postIDs []int) (map[int][]string, error)
+	GetPostsByTag(ctx context.Context, tag string, limit int, afterID int) ([]*repo_models.Post, error)
+	SearchTags(ctx context.Context, prefix string, limit int) ([]*repo_models.Tag, error)
+	GetTagsByNames(ctx context.Context, names []string) ([]*repo_models.Tag, error)
+	FollowTag(ctx context.Context, userID int, name string) error
+	UnfollowTag(ctx context.Context, userID int, name string) error
+	GetFollowedTags(ctx context.Context, userID int) ([]string, error)
+}
+
+type AttachmentRepoInterface interface {
+	CreateAttachment(ctx context.Context, attachment *repo_models.Attachment) (*repo_models.Attachment, error)
+	SetPostAttachments(ctx context.Context, postID int, userID int, ids []int) error
+	GetAttachmentsByPostIDs(ctx context.Context, postIDs []int) (map[int][]*repo_models.Attachment, error)
+	GetAttachmentsByUserID(ctx context.Context, userID int) ([]*repo_models.Attachment, error)
+	DeleteAttachments(ctx context.Context, ids []int) error
+	DeleteStaleAttachments(ctx context.Context, before time.Time) ([]*repo_models.Attachment, error)
+}
+
+type BookmarkRepoInterface interface {
+	BookmarkPost(ctx context.Context, userID int, postID int) error
+	UnbookmarkPost(ctx context.Context, userID int, postID int) error
+	GetBookmarks(ctx context.Context, userID int, limit int, afterID int) ([]*repo_models.Bookmark, error)
+	CountBookmarksByPostIDs(ctx context.Context, postIDs []int) (map[int]int, error)
+	GetBookmarkedPostIDs(ctx context.Context, userID int, postIDs []int) (map[int]bool, error)
+}
+
+type FollowRepoInterface interface {
+	FollowUser(ctx context.Context, followerID int, userID int) error
+	UnfollowUser(ctx context.Context, followerID int, userID int) error
+	GetFollowers(ctx context.Context, userID int, limit int, afterID int) ([]*repo_models.Follow, error)
+	GetFollowing(ctx context.Context, userID int, limit int, afterID int) ([]*repo_models.Follow, error)
+	CountFollowers(ctx context.Context, userID int) (int, error)
+	CountFollowing(ctx context.Context, userID int) (int, error)
+	GetFeed(ctx context.Context, userID int, limit int, after *repo_models.FeedCursor) ([]*repo_models.Post, error)
+}
+
+type CommentEventRepoInterface interface {
+	GetCommentEventsSince(ctx context.Context, postID int, sinceID int) ([]*repo_models.CommentEvent, error)
+}
+
+type NotificationRepoInterface interface {
+	CreateNotification(ctx context.Context, userID int, actorID int, notificationType string, postID int, commentID int) (*repo_models.Notification, error)
+	GetNotifications(ctx context.Context, userID int, limit int, afterID int, unreadOnly bool) ([]*repo_models.Notification, error)
+	MarkNotificationsRead(ctx context.Context, userID int, ids []int) (int, error)
+}
+
+// сколько последних событий комментариев хранится на пост для досылки после переподключения
+const commentEventLogSize = 100
+
+const notificationBufferSize = 16
+
+const postBufferSize = 16
+
+// сколько ревизий текста держать отрендеренными в HTML
+const renderCacheSize = 10000
+
 type Resolver struct {
-	UserRepo    UserRepoInterface
-	PostRepo    PostRepoInterface
-	CommentRepo CommentRepoInterface
+	UserRepo     UserRepoInterface
+	PostRepo     PostRepoInterface
+	CommentRepo  CommentRepoInterface
+	TagRepo      TagRepoInterface
+	BookmarkRepo BookmarkRepoInterface
+	FollowRepo   FollowRepoInterface
+	PostHub      *hub[*model.Post]
+	Renderer     *markdown.Renderer
+
+	// Blobs и UploadMaxSize задаются в main, там же настраивается хранилище
+	AttachmentRepo AttachmentRepoInterface
+	Blobs          storage.BlobStore
+	UploadMaxSize  int64
+
+	CommentEventRepo CommentEventRepoInterface
+	CommentHub       *hub[commentEvent]
+
+	NotificationRepo NotificationRepoInterface
+	NotificationHub  *hub[*model.Notification]
 }
 
-func NewPgResolver(db *sql.DB) *Resolver {
+func NewPgResolver(db *pg_repository.Cluster) *Resolver {
 	return &Resolver{
-		UserRepo:    pg_repository.NewUserRepository(db),
-		PostRepo:    pg_repository.NewPostRepository(db),
-		CommentRepo: pg_repository.NewCommentRepository(db),
+		UserRepo:     pg_repository.NewUserRepository(db),
+		PostRepo:     pg_repository.NewPostRepository(db),
+		CommentRepo:  pg_repository.NewCommentRepository(db, commentEventLogSize),
+		TagRepo:      pg_repository.NewTagRepository(db),
+		BookmarkRepo: pg_repository.NewBookmarkRepository(db),
+		FollowRepo:   pg_repository.NewFollowRepository(db),
+		PostHub:      newHub[*model.Post](postBufferSize),
+		Renderer:     markdown.New(renderCacheSize),
+
+		AttachmentRepo: pg_repository.NewAttachmentRepository(db),
+
+		CommentEventRepo: pg_repository.NewCommentEventRepository(db),
+		CommentHub:       newHub[commentEvent](commentEventLogSize).withObserver(observeCommentSubscriptions),
+
+		NotificationRepo: pg_repository.NewNotificationRepository(db),
+		NotificationHub:  newHub[*model.Notification](notificationBufferSize),
 	}
 }
 
 func NewMemResolver() *Resolver {
+	posts := mem_repository.NewPostRepository()
+	tags := mem_repository.NewTagRepository(posts)
+	commentEvents := mem_repository.NewCommentEventRepository(commentEventLogSize)
+	comments := mem_repository.NewCommentRepository(posts, commentEvents)
 	return &Resolver{
-		UserRepo:    mem_repository.NewUserRepository(),
-		PostRepo:    mem_repository.NewPostRepository(),
-		CommentRepo: mem_repository.NewCommentRepository(),
+		UserRepo:     mem_repository.NewUserRepository(),
+		PostRepo:     posts,
+		CommentRepo:  comments,
+		TagRepo:      tags,
+		BookmarkRepo: mem_repository.NewBookmarkRepository(posts),
+		FollowRepo:   mem_repository.NewFollowRepository(posts, tags),
+		PostHub:      newHub[*model.Post](postBufferSize),
+		Renderer:     markdown.New(renderCacheSize),
+
+		AttachmentRepo: mem_repository.NewAttachmentRepository(),
+
+		CommentEventRepo: commentEvents,
+		CommentHub:       newHub[commentEvent](commentEventLogSize).withObserver(observeCommentSubscriptions),
+
+		NotificationRepo: mem_repository.NewNotificationRepository(comments),
+		NotificationHub:  newHub[*model.Notification](notificationBufferSize),
 	}
 }
@@ -0,0 +1,46 @@
+package graph
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// PublishScheduled раз в interval публикует запланированные посты, у которых наступило время,
+// и рассылает их подписчикам postPublished. Работает до отмены ctx.
+func (r *Resolver) PublishScheduled(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.publishDuePosts(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Resolver) publishDuePosts(ctx context.Context) {
+	posts, err := r.PostRepo.PublishDuePosts(ctx, time.Now())
+	if err != nil {
+		slog.Error("failed to publish scheduled posts", "error", err)
+		return
+	}
+	if len(posts) == 0 {
+		return
+	}
+
+	model_posts, err := r.postsToModel(ctx, posts)
+	if err != nil {
+		slog.Error("failed to load published posts", "error", err)
+		return
+	}
+
+	for _, post := range model_posts {
+		slog.Info("scheduled post published", "post_id", post.ID)
+		r.PostHub.Publish(allPostsKey, post)
+	}
+}
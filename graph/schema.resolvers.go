@@ -9,12 +9,22 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
-	"sync"
+	"strings"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/AntonCkya/ozon_habr/graph/model"
 	"github.com/AntonCkya/ozon_habr/internal/auth"
+	"github.com/AntonCkya/ozon_habr/internal/logging"
+	"github.com/AntonCkya/ozon_habr/internal/metrics"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
 )
 
+// ContentHTML is the resolver for the contentHtml field.
+func (r *commentResolver) ContentHTML(ctx context.Context, obj *model.Comment) (string, error) {
+	return r.Renderer.Render(ctx, string(obj.ContentFormat), obj.Content)
+}
+
 // CreatePost is the resolver for the createPost field.
 func (r *mutationResolver) CreatePost(ctx context.Context, input model.PostInput) (*model.Post, error) {
 	userID, ok := auth.GetUserID(ctx)
@@ -26,36 +36,86 @@ func (r *mutationResolver) CreatePost(ctx context.Context, input model.PostInput
 		return nil, fmt.Errorf("title and content are required")
 	}
 
+	tags, err := normalizeTags(input.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment_ids, err := attachmentIDs(input.AttachmentIds)
+	if err != nil {
+		return nil, err
+	}
+
+	status, publishAt, err := postStatus(input, nil, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	contentFormat := repo_models.ContentFormatPlain
+	if input.ContentFormat != nil {
+		contentFormat = string(*input.ContentFormat)
+	}
+
 	post, err := r.PostRepo.CreatePost(
 		ctx,
 		input.Title,
 		input.Content,
+		contentFormat,
 		userID,
 		input.Commentable,
+		status,
+		publishAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create post: %w", err)
 	}
 
-	fmt.Printf("User %d created post %d\n", userID, post.ID)
+	if len(tags) > 0 {
+		if err := r.TagRepo.SetPostTags(ctx, post.ID, tags); err != nil {
+			// как и с вложениями, пост без тегов не оставляем
+			r.discardPost(ctx, post.ID)
+			return nil, fmt.Errorf("failed to set tags: %w", err)
+		}
+	}
+
+	attachments := []*model.Attachment{}
+	if len(attachment_ids) > 0 {
+		if err := r.AttachmentRepo.SetPostAttachments(ctx, post.ID, userID, attachment_ids); err != nil {
+			// чужое или занятое вложение - пост без вложений не оставляем
+			r.discardPost(ctx, post.ID)
+			return nil, fmt.Errorf("failed to set attachments: %w", err)
+		}
+		attachments, err = r.postAttachments(ctx, post.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	logging.FromContext(ctx).Info("post created", "post_id", post.ID, "status", post.Status)
 
 	user, err := r.UserRepo.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	model_user := model.User{
-		ID:       strconv.Itoa(userID),
-		Username: user.Username,
-	}
+	model_user := userToModel(user)
 
 	model_post := model.Post{
-		ID:          strconv.Itoa(post.ID),
-		Title:       post.Title,
-		Content:     post.Content,
-		User:        &model_user,
-		Commentable: post.Commentable,
-		Comments:    []*model.Comment{},
+		ID:            strconv.Itoa(post.ID),
+		Title:         post.Title,
+		Content:       post.Content,
+		ContentFormat: model.ContentFormat(post.ContentFormat),
+		User:          model_user,
+		Commentable:   post.Commentable,
+		Comments:      []*model.Comment{},
+		Tags:          tags,
+		Status:        model.PostStatus(post.Status),
+		PublishAt:     post.PublishAt,
+		Attachments:   attachments,
+	}
+
+	if post.Status == repo_models.PostStatusPublished {
+		r.PostHub.Publish(allPostsKey, &model_post)
 	}
 
 	return &model_post, nil
@@ -72,6 +132,24 @@ func (r *mutationResolver) UpdatePost(ctx context.Context, id string, input mode
 		return nil, fmt.Errorf("title and content are required")
 	}
 
+	var tags []string
+	if input.Tags != nil {
+		var err error
+		tags, err = normalizeTags(input.Tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var attachment_ids []int
+	if input.AttachmentIds != nil {
+		var err error
+		attachment_ids, err = attachmentIDs(input.AttachmentIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	post_id, err := strconv.Atoi(id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert post id to int: %w", err)
@@ -85,29 +163,52 @@ func (r *mutationResolver) UpdatePost(ctx context.Context, id string, input mode
 		return nil, fmt.Errorf("failed to update post, check permission")
 	}
 
+	status, publishAt, err := postStatus(input, prev_post, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	contentFormat := prev_post.ContentFormat
+	if input.ContentFormat != nil {
+		contentFormat = string(*input.ContentFormat)
+	}
+
 	post, err := r.PostRepo.UpdatePost(
 		ctx,
 		post_id,
 		input.Title,
 		input.Content,
+		contentFormat,
 		userID,
 		input.Commentable,
+		status,
+		publishAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update post: %w", err)
 	}
 
-	fmt.Printf("User %d updated post %d\n", userID, post.ID)
+	if input.Tags != nil {
+		if err := r.TagRepo.SetPostTags(ctx, post.ID, tags); err != nil {
+			// сам пост уже обновлён, старые теги остаются: SetPostTags меняет их целиком или никак
+			return nil, fmt.Errorf("failed to set tags: %w", err)
+		}
+	}
+
+	if input.AttachmentIds != nil {
+		if err := r.AttachmentRepo.SetPostAttachments(ctx, post.ID, userID, attachment_ids); err != nil {
+			return nil, fmt.Errorf("failed to set attachments: %w", err)
+		}
+	}
+
+	logging.FromContext(ctx).Info("post updated", "post_id", post.ID, "status", post.Status)
 
 	user, err := r.UserRepo.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	model_user := model.User{
-		ID:       strconv.Itoa(userID),
-		Username: user.Username,
-	}
+	model_user := userToModel(user)
 
 	comments, err := r.CommentRepo.GetCommentsByPostID(
 		ctx,
@@ -128,15 +229,24 @@ func (r *mutationResolver) UpdatePost(ctx context.Context, id string, input mode
 		return nil, fmt.Errorf("failed to get users: %w", err)
 	}
 
+	post_tags, err := r.TagRepo.GetTagsByPostIDs(ctx, []int{post.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	attachments, err := r.postAttachments(ctx, post.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	bookmark_counts, bookmarked, err := r.bookmarkStats(ctx, []int{post.ID})
+	if err != nil {
+		return nil, err
+	}
+
 	var model_comments []*model.Comment
 	for _, comment := range comments {
 		currUserId := comment.UserID
-		var username string
-		for _, user := range comment_users {
-			if user.ID == currUserId {
-				username = user.Username
-			}
-		}
 		var ParentId *string
 		if comment.ParentID == nil {
 			ParentId = nil
@@ -145,24 +255,36 @@ func (r *mutationResolver) UpdatePost(ctx context.Context, id string, input mode
 			ParentId = &parentIdValue
 		}
 		model_comments = append(model_comments, &model.Comment{
-			ID:      strconv.Itoa(comment.ID),
-			Content: comment.Content,
-			User: &model.User{
-				ID:       strconv.Itoa(currUserId),
-				Username: username,
-			},
-			ParentID: ParentId,
-			PostID:   strconv.Itoa(comment.PostID),
+			ID:            strconv.Itoa(comment.ID),
+			Content:       comment.Content,
+			ContentFormat: model.ContentFormat(comment.ContentFormat),
+			User:          findUser(comment_users, currUserId),
+			ParentID:      ParentId,
+			PostID:        strconv.Itoa(comment.PostID),
 		})
 	}
 
 	model_post := model.Post{
-		ID:          strconv.Itoa(post.ID),
-		Title:       post.Title,
-		Content:     post.Content,
-		User:        &model_user,
-		Commentable: post.Commentable,
-		Comments:    model_comments,
+		ID:            strconv.Itoa(post.ID),
+		Title:         post.Title,
+		Content:       post.Content,
+		ContentFormat: model.ContentFormat(post.ContentFormat),
+		User:          model_user,
+		Commentable:   post.Commentable,
+		Comments:      model_comments,
+		Tags:          post_tags[post.ID],
+		Status:        model.PostStatus(post.Status),
+		PublishAt:     post.PublishAt,
+		Attachments:   attachments,
+		IsBookmarked:  bookmarked[post.ID],
+		BookmarkCount: int32(bookmark_counts[post.ID]),
+		CommentCount:  int32(post.CommentCount),
+		LastCommentAt: post.LastCommentAt,
+	}
+
+	// возврат из архива - не новая публикация
+	if post.Status == repo_models.PostStatusPublished && prev_post.Status != repo_models.PostStatusPublished && prev_post.Status != repo_models.PostStatusArchived {
+		r.PostHub.Publish(allPostsKey, &model_post)
 	}
 
 	return &model_post, nil
@@ -188,6 +310,11 @@ func (r *mutationResolver) DeletePost(ctx context.Context, id string) (bool, err
 		return false, fmt.Errorf("failed to delete post, check permission")
 	}
 
+	attachments, err := r.AttachmentRepo.GetAttachmentsByPostIDs(ctx, []int{post_id})
+	if err != nil {
+		return false, fmt.Errorf("failed to get attachments: %w", err)
+	}
+
 	err = r.PostRepo.DeletePost(
 		ctx,
 		post_id,
@@ -196,11 +323,40 @@ func (r *mutationResolver) DeletePost(ctx context.Context, id string) (bool, err
 		return false, fmt.Errorf("failed to delete post: %w", err)
 	}
 
-	fmt.Printf("User %d deleted post %d\n", userID, post_id)
+	// пост уже удалён, оставшиеся вложения подберёт SweepAttachments
+	if err := r.deletePostAttachments(ctx, attachments[post_id]); err != nil {
+		logging.FromContext(ctx).Error("failed to delete post attachments", "post_id", post_id, "error", err)
+	}
+
+	logging.FromContext(ctx).Info("post deleted", "post_id", post_id)
 
 	return true, nil
 }
 
+// UploadAttachment is the resolver for the uploadAttachment field.
+func (r *mutationResolver) UploadAttachment(ctx context.Context, file graphql.Upload) (*model.Attachment, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
+
+	upload, err := r.storeUpload(ctx, userID, file)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment, err := r.AttachmentRepo.CreateAttachment(ctx, upload)
+	if err != nil {
+		r.Blobs.Delete(ctx, upload.Key)
+		r.Blobs.Delete(ctx, upload.ThumbnailKey)
+		return nil, fmt.Errorf("failed to create attachment: %w", err)
+	}
+
+	logging.FromContext(ctx).Info("attachment uploaded", "attachment_id", attachment.ID, "content_type", attachment.ContentType, "size", attachment.Size)
+
+	return r.attachmentToModel(attachment), nil
+}
+
 // CreateComment is the resolver for the createComment field.
 func (r *mutationResolver) CreateComment(ctx context.Context, input model.CommentInput) (*model.Comment, error) {
 	userID, ok := auth.GetUserID(ctx)
@@ -238,10 +394,19 @@ func (r *mutationResolver) CreateComment(ctx context.Context, input model.Commen
 	if !post.Commentable {
 		return nil, fmt.Errorf("failed to comment unommentable post")
 	}
+	if post.Status != repo_models.PostStatusPublished {
+		return nil, fmt.Errorf("failed to comment unpublished post")
+	}
+
+	contentFormat := repo_models.ContentFormatPlain
+	if input.ContentFormat != nil {
+		contentFormat = string(*input.ContentFormat)
+	}
 
 	comment, err := r.CommentRepo.CreateComment(
 		ctx,
 		input.Content,
+		contentFormat,
 		userID,
 		PostId,
 		ParentId,
@@ -250,37 +415,36 @@ func (r *mutationResolver) CreateComment(ctx context.Context, input model.Commen
 		return nil, fmt.Errorf("failed to create comment: %w", err)
 	}
 
-	fmt.Printf("User %d commented post %d with comment %d\n", userID, PostId, comment.ID)
+	logging.FromContext(ctx).Info("comment created", "post_id", PostId, "comment_id", comment.ID)
 
 	user, err := r.UserRepo.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	model_user := model.User{
-		ID:       strconv.Itoa(userID),
-		Username: user.Username,
-	}
+	model_user := userToModel(user)
 
 	model_comment := model.Comment{
-		ID:       strconv.Itoa(comment.ID),
-		Content:  comment.Content,
-		User:     &model_user,
-		ParentID: input.ParentID,
-		PostID:   strconv.Itoa(comment.PostID),
+		ID:            strconv.Itoa(comment.ID),
+		Content:       comment.Content,
+		ContentFormat: model.ContentFormat(comment.ContentFormat),
+		User:          model_user,
+		ParentID:      input.ParentID,
+		PostID:        strconv.Itoa(comment.PostID),
 	}
 
-	for id, observer := range commentChannel {
-		if id == input.PostID {
-			observer <- &model_comment
-		}
-	}
+	eventId := strconv.Itoa(comment.EventID)
+	model_comment.EventID = &eventId
+
+	r.CommentHub.Publish(PostId, commentEvent{id: comment.EventID, comment: &model_comment})
+
+	r.notifyAboutComment(ctx, post, comment, model_user)
 
 	return &model_comment, nil
 }
 
 // UpdateComment is the resolver for the updateComment field.
-func (r *mutationResolver) UpdateComment(ctx context.Context, id string, content string) (*model.Comment, error) {
+func (r *mutationResolver) UpdateComment(ctx context.Context, id string, content string, contentFormat *model.ContentFormat) (*model.Comment, error) {
 	userID, ok := auth.GetUserID(ctx)
 	if !ok {
 		return nil, errors.New("invalid user")
@@ -309,26 +473,29 @@ func (r *mutationResolver) UpdateComment(ctx context.Context, id string, content
 		return nil, fmt.Errorf("failed to update comment, check permission")
 	}
 
+	format := prev_comment.ContentFormat
+	if contentFormat != nil {
+		format = string(*contentFormat)
+	}
+
 	comment, err := r.CommentRepo.UpdateComment(
 		ctx,
 		commentId,
 		content,
+		format,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update comment: %w", err)
 	}
 
-	fmt.Printf("User %d update comment %d\n", userID, comment.ID)
+	logging.FromContext(ctx).Info("comment updated", "comment_id", comment.ID)
 
 	user, err := r.UserRepo.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	model_user := model.User{
-		ID:       strconv.Itoa(userID),
-		Username: user.Username,
-	}
+	model_user := userToModel(user)
 
 	var ParentId *string
 	if comment.ParentID == nil {
@@ -339,11 +506,12 @@ func (r *mutationResolver) UpdateComment(ctx context.Context, id string, content
 	}
 
 	model_comment := model.Comment{
-		ID:       strconv.Itoa(comment.ID),
-		Content:  comment.Content,
-		User:     &model_user,
-		ParentID: ParentId,
-		PostID:   strconv.Itoa(comment.PostID),
+		ID:            strconv.Itoa(comment.ID),
+		Content:       comment.Content,
+		ContentFormat: model.ContentFormat(comment.ContentFormat),
+		User:          model_user,
+		ParentID:      ParentId,
+		PostID:        strconv.Itoa(comment.PostID),
 	}
 
 	return &model_comment, nil
@@ -380,223 +548,240 @@ func (r *mutationResolver) DeleteComment(ctx context.Context, id string) (bool,
 		return false, fmt.Errorf("failed to delete comment: %w", err)
 	}
 
-	fmt.Printf("User %d deleted comment %d\n", userID, commentId)
+	logging.FromContext(ctx).Info("comment deleted", "comment_id", commentId)
 
 	return true, nil
 }
 
-// Posts is the resolver for the posts field.
-func (r *queryResolver) Posts(ctx context.Context, limit *int32, offset *int32) ([]*model.Post, error) {
+// FollowTag is the resolver for the followTag field.
+func (r *mutationResolver) FollowTag(ctx context.Context, name string) (*model.Tag, error) {
 	userID, ok := auth.GetUserID(ctx)
 	if !ok {
 		return nil, errors.New("invalid user")
 	}
 
-	fmt.Printf("User %d finding posts, limit %d, offset %d\n", userID, limit, offset)
+	tagName, err := normalizeTag(name)
+	if err != nil {
+		return nil, err
+	}
 
-	posts, err := r.PostRepo.GetPosts(ctx, int(*limit), int(*offset))
+	err = r.TagRepo.FollowTag(ctx, userID, tagName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get posts: %w", err)
+		return nil, fmt.Errorf("failed to follow tag: %w", err)
 	}
-	var userIds []int
-	var postIds []int
-	for _, post := range posts {
-		userIds = append(userIds, post.UserID)
-		postIds = append(postIds, post.ID)
+
+	logging.FromContext(ctx).Info("tag followed", "tag", tagName)
+
+	return r.getTag(ctx, userID, tagName)
+}
+
+// UnfollowTag is the resolver for the unfollowTag field.
+func (r *mutationResolver) UnfollowTag(ctx context.Context, name string) (*model.Tag, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
 	}
-	users, err := r.UserRepo.GetUsersByIDs(ctx, userIds)
+
+	tagName, err := normalizeTag(name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get users: %w", err)
+		return nil, err
 	}
 
-	comments, err := r.CommentRepo.GetCommentsByPostIDs(ctx, postIds)
+	err = r.TagRepo.UnfollowTag(ctx, userID, tagName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get comments: %w", err)
+		return nil, fmt.Errorf("failed to unfollow tag: %w", err)
 	}
 
-	var commentUsersIds []int
-	for _, comment := range comments {
-		commentUsersIds = append(commentUsersIds, comment.UserID)
+	logging.FromContext(ctx).Info("tag unfollowed", "tag", tagName)
+
+	return r.getTag(ctx, userID, tagName)
+}
+
+// BookmarkPost is the resolver for the bookmarkPost field.
+func (r *mutationResolver) BookmarkPost(ctx context.Context, postID string) (*model.Post, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
 	}
-	comment_users, err := r.UserRepo.GetUsersByIDs(ctx, commentUsersIds)
+
+	post_id, err := strconv.Atoi(postID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get users: %w", err)
+		return nil, fmt.Errorf("failed to convert post id to int: %w", err)
 	}
 
-	var model_posts []*model.Post
-	for i := 0; i < len(posts); i++ {
-		currUserId := posts[i].UserID
-		var username string
-		for _, user := range users {
-			if user.ID == currUserId {
-				username = user.Username
-			}
-		}
+	post, err := r.PostRepo.GetPostByID(ctx, post_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+	if !canViewPost(post, userID) {
+		return nil, fmt.Errorf("failed to get post: post not found")
+	}
 
-		var model_comments []*model.Comment
-		for _, comment := range comments {
-			if comment.PostID != posts[i].ID {
-				continue
-			}
-			currUserCommentId := comment.UserID
-			var username string
-			for _, user := range comment_users {
-				if user.ID == currUserCommentId {
-					username = user.Username
-				}
-			}
-			var ParentId *string
-			if comment.ParentID == nil {
-				ParentId = nil
-			} else {
-				parentIdValue := strconv.Itoa(*comment.ParentID)
-				ParentId = &parentIdValue
-			}
-			model_comments = append(model_comments, &model.Comment{
-				ID:      strconv.Itoa(comment.ID),
-				Content: comment.Content,
-				User: &model.User{
-					ID:       strconv.Itoa(currUserCommentId),
-					Username: username,
-				},
-				ParentID: ParentId,
-				PostID:   strconv.Itoa(comment.PostID),
-			})
-		}
+	if err := r.BookmarkRepo.BookmarkPost(ctx, userID, post_id); err != nil {
+		return nil, fmt.Errorf("failed to bookmark post: %w", err)
+	}
 
-		model_posts = append(model_posts, &model.Post{
-			ID:      strconv.Itoa(posts[i].ID),
-			Title:   posts[i].Title,
-			Content: posts[i].Content,
-			User: &model.User{
-				ID:       strconv.Itoa(currUserId),
-				Username: username,
-			},
-			Commentable: posts[i].Commentable,
-			Comments:    model_comments,
-		})
+	logging.FromContext(ctx).Info("post bookmarked", "post_id", post_id)
+
+	return r.getVisiblePost(ctx, userID, post_id)
+}
+
+// UnbookmarkPost is the resolver for the unbookmarkPost field.
+func (r *mutationResolver) UnbookmarkPost(ctx context.Context, postID string) (*model.Post, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
+
+	post_id, err := strconv.Atoi(postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert post id to int: %w", err)
+	}
+
+	if err := r.BookmarkRepo.UnbookmarkPost(ctx, userID, post_id); err != nil {
+		return nil, fmt.Errorf("failed to unbookmark post: %w", err)
 	}
 
-	return model_posts, nil
+	logging.FromContext(ctx).Info("post unbookmarked", "post_id", post_id)
+
+	return r.getVisiblePost(ctx, userID, post_id)
 }
 
-// PostsByUser is the resolver for the postsByUser field.
-func (r *queryResolver) PostsByUser(ctx context.Context, limit *int32, offset *int32, userID string) ([]*model.Post, error) {
-	queryUserID, ok := auth.GetUserID(ctx)
+// FollowUser is the resolver for the followUser field.
+func (r *mutationResolver) FollowUser(ctx context.Context, userID string) (*model.User, error) {
+	currentUserID, ok := auth.GetUserID(ctx)
 	if !ok {
 		return nil, errors.New("invalid user")
 	}
 
-	fmt.Printf("User %d finding posts\n", queryUserID)
+	followee_id, err := strconv.Atoi(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert user id to int: %w", err)
+	}
+	if followee_id == currentUserID {
+		return nil, fmt.Errorf("can't follow yourself")
+	}
 
-	dbUserId, err := strconv.Atoi(userID)
+	followee, err := r.UserRepo.GetUserByID(ctx, followee_id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	posts, err := r.PostRepo.GetPostsByUserId(ctx, int(*limit), int(*offset), dbUserId)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get posts: %w", err)
+	if err := r.FollowRepo.FollowUser(ctx, currentUserID, followee.ID); err != nil {
+		return nil, fmt.Errorf("failed to follow user: %w", err)
 	}
-	var userIds []int
-	var postIds []int
-	for _, post := range posts {
-		userIds = append(userIds, post.UserID)
-		postIds = append(postIds, post.ID)
+
+	logging.FromContext(ctx).Info("user followed", "followee_id", followee.ID)
+
+	return userToModel(followee), nil
+}
+
+// UnfollowUser is the resolver for the unfollowUser field.
+func (r *mutationResolver) UnfollowUser(ctx context.Context, userID string) (*model.User, error) {
+	currentUserID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
 	}
-	users, err := r.UserRepo.GetUsersByIDs(ctx, userIds)
+
+	followee_id, err := strconv.Atoi(userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get users: %w", err)
+		return nil, fmt.Errorf("failed to convert user id to int: %w", err)
 	}
 
-	comments, err := r.CommentRepo.GetCommentsByPostIDs(ctx, postIds)
+	followee, err := r.UserRepo.GetUserByID(ctx, followee_id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get comments: %w", err)
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	var commentUsersIds []int
-	for _, comment := range comments {
-		commentUsersIds = append(commentUsersIds, comment.UserID)
-	}
-	comment_users, err := r.UserRepo.GetUsersByIDs(ctx, commentUsersIds)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get users: %w", err)
+	if err := r.FollowRepo.UnfollowUser(ctx, currentUserID, followee.ID); err != nil {
+		return nil, fmt.Errorf("failed to unfollow user: %w", err)
 	}
 
-	var model_posts []*model.Post
-	for i := 0; i < len(posts); i++ {
-		currUserId := posts[i].UserID
-		var username string
-		for _, user := range users {
-			if user.ID == currUserId {
-				username = user.Username
-			}
-		}
+	logging.FromContext(ctx).Info("user unfollowed", "followee_id", followee.ID)
 
-		var model_comments []*model.Comment
-		for _, comment := range comments {
-			if comment.PostID != posts[i].ID {
-				continue
-			}
-			currUserCommentId := comment.UserID
-			var username string
-			for _, user := range comment_users {
-				if user.ID == currUserCommentId {
-					username = user.Username
-				}
-			}
-			var ParentId *string
-			if comment.ParentID == nil {
-				ParentId = nil
-			} else {
-				parentIdValue := strconv.Itoa(*comment.ParentID)
-				ParentId = &parentIdValue
+	return userToModel(followee), nil
+}
+
+// MarkNotificationsRead is the resolver for the markNotificationsRead field.
+func (r *mutationResolver) MarkNotificationsRead(ctx context.Context, ids []string) (int32, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return 0, errors.New("invalid user")
+	}
+
+	var notificationIds []int
+	if ids != nil {
+		notificationIds = make([]int, 0, len(ids))
+		for _, id := range ids {
+			notificationId, err := strconv.Atoi(id)
+			if err != nil {
+				return 0, fmt.Errorf("failed to convert notification id to int: %w", err)
 			}
-			model_comments = append(model_comments, &model.Comment{
-				ID:      strconv.Itoa(comment.ID),
-				Content: comment.Content,
-				User: &model.User{
-					ID:       strconv.Itoa(currUserCommentId),
-					Username: username,
-				},
-				ParentID: ParentId,
-				PostID:   strconv.Itoa(comment.PostID),
-			})
+			notificationIds = append(notificationIds, notificationId)
 		}
+	}
 
-		model_posts = append(model_posts, &model.Post{
-			ID:      strconv.Itoa(posts[i].ID),
-			Title:   posts[i].Title,
-			Content: posts[i].Content,
-			User: &model.User{
-				ID:       strconv.Itoa(currUserId),
-				Username: username,
-			},
-			Commentable: posts[i].Commentable,
-			Comments:    model_comments,
-		})
+	marked, err := r.NotificationRepo.MarkNotificationsRead(ctx, userID, notificationIds)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
 	}
 
-	return model_posts, nil
+	return int32(marked), nil
 }
 
-// Post is the resolver for the post field.
-func (r *queryResolver) Post(ctx context.Context, id string) (*model.Post, error) {
+// UpdateProfile is the resolver for the updateProfile field.
+func (r *mutationResolver) UpdateProfile(ctx context.Context, input model.ProfileInput) (*model.User, error) {
 	userID, ok := auth.GetUserID(ctx)
 	if !ok {
 		return nil, errors.New("invalid user")
 	}
 
-	post_id, err := strconv.Atoi(id)
+	user, err := r.UserRepo.GetUserByID(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert post id to int: %w", err)
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	fmt.Printf("User %d finding post %d\n", userID, post_id)
+	displayName, bio, avatarUrl := user.DisplayName, user.Bio, user.AvatarURL
+	if input.DisplayName != nil {
+		displayName = *input.DisplayName
+	}
+	if input.Bio != nil {
+		bio = *input.Bio
+	}
+	if input.AvatarURL != nil {
+		avatarUrl = *input.AvatarURL
+	}
 
-	post, err := r.PostRepo.GetPostByID(ctx, post_id)
+	if len(displayName) > 255 {
+		return nil, fmt.Errorf("display name is too long")
+	}
+	if len(bio) > 2000 {
+		return nil, fmt.Errorf("bio is too long")
+	}
+	if avatarUrl != "" && !isHTTPURL(avatarUrl) {
+		return nil, fmt.Errorf("avatar url must be an http(s) url")
+	}
+
+	user, err = r.UserRepo.UpdateProfile(ctx, userID, displayName, bio, avatarUrl)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get post: %w", err)
+		return nil, fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	logging.FromContext(ctx).Info("profile updated")
+
+	return userToModel(user), nil
+}
+
+// ContentHTML is the resolver for the contentHtml field.
+func (r *postResolver) ContentHTML(ctx context.Context, obj *model.Post) (string, error) {
+	return r.Renderer.Render(ctx, string(obj.ContentFormat), obj.Content)
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
 	}
 
 	user, err := r.UserRepo.GetUserByID(ctx, userID)
@@ -604,85 +789,215 @@ func (r *queryResolver) Post(ctx context.Context, id string) (*model.Post, error
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	model_user := model.User{
-		ID:       strconv.Itoa(userID),
-		Username: user.Username,
+	return userToModel(user), nil
+}
+
+// User is the resolver for the user field.
+func (r *queryResolver) User(ctx context.Context, id string) (*model.User, error) {
+	_, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
 	}
 
-	comments, err := r.CommentRepo.GetCommentsByPostID(
-		ctx,
-		post.ID,
-		10,
-		0,
-	)
+	dbUserId, err := strconv.Atoi(id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get comments: %w", err)
+		return nil, fmt.Errorf("failed to convert user id to int: %w", err)
 	}
 
-	var commentUsersIds []int
-	for _, comment := range comments {
-		commentUsersIds = append(commentUsersIds, comment.UserID)
+	logging.FromContext(ctx).Debug("finding user", "target_user_id", dbUserId)
+
+	user, err := r.UserRepo.GetUserByID(ctx, dbUserId)
+	if errors.Is(err, repo_models.ErrUserNotFound) {
+		return nil, nil
 	}
-	comment_users, err := r.UserRepo.GetUsersByIDs(ctx, commentUsersIds)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get users: %w", err)
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	var model_comments []*model.Comment
-	for _, comment := range comments {
-		currUserId := comment.UserID
-		var username string
-		for _, user := range comment_users {
-			if user.ID == currUserId {
-				username = user.Username
-			}
-		}
-		var ParentId *string
-		if comment.ParentID == nil {
-			ParentId = nil
-		} else {
-			parentIdValue := strconv.Itoa(*comment.ParentID)
-			ParentId = &parentIdValue
-		}
-		model_comments = append(model_comments, &model.Comment{
-			ID:      strconv.Itoa(comment.ID),
-			Content: comment.Content,
-			User: &model.User{
-				ID:       strconv.Itoa(currUserId),
-				Username: username,
-			},
-			ParentID: ParentId,
-			PostID:   strconv.Itoa(comment.PostID),
-		})
+	return userToModel(user), nil
+}
+
+// UserByUsername is the resolver for the userByUsername field.
+func (r *queryResolver) UserByUsername(ctx context.Context, username string) (*model.User, error) {
+	_, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
 	}
 
-	model_post := model.Post{
-		ID:          strconv.Itoa(post.ID),
-		Title:       post.Title,
-		Content:     post.Content,
-		User:        &model_user,
-		Commentable: post.Commentable,
-		Comments:    model_comments,
+	logging.FromContext(ctx).Debug("finding user", "username", username)
+
+	user, err := r.UserRepo.GetUserByUsername(ctx, username)
+	if errors.Is(err, repo_models.ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	return &model_post, nil
+	return userToModel(user), nil
 }
 
-// Comments is the resolver for the comments field.
-func (r *queryResolver) Comments(ctx context.Context, limit *int32, offset *int32, postID string) ([]*model.Comment, error) {
-	userID, ok := auth.GetUserID(ctx)
+// Posts is the resolver for the posts field.
+func (r *queryResolver) Posts(ctx context.Context, limit *int32, offset *int32, orderBy *model.PostOrder) ([]*model.Post, error) {
+	_, ok := auth.GetUserID(ctx)
 	if !ok {
 		return nil, errors.New("invalid user")
 	}
 
-	post_id, err := strconv.Atoi(postID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to convert post id to int: %w", err)
+	var order string
+	if orderBy != nil {
+		order = string(*orderBy)
 	}
 
-	fmt.Printf("User %d finding commens on post %d\n", userID, post_id)
+	logging.FromContext(ctx).Debug("finding posts", "limit", *limit, "offset", *offset, "order", order)
 
-	comments, err := r.CommentRepo.GetCommentsByPostID(
+	posts, err := r.PostRepo.GetPosts(ctx, int(*limit), int(*offset), order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts: %w", err)
+	}
+	return r.postsToModel(ctx, posts)
+}
+
+// PostsByUser is the resolver for the postsByUser field.
+func (r *queryResolver) PostsByUser(ctx context.Context, limit *int32, offset *int32, userID string) ([]*model.Post, error) {
+	_, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
+
+	dbUserId, err := strconv.Atoi(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	logging.FromContext(ctx).Debug("finding posts by user", "target_user_id", dbUserId)
+
+	posts, err := r.PostRepo.GetPostsByUserId(ctx, int(*limit), int(*offset), dbUserId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts: %w", err)
+	}
+	return r.postsToModel(ctx, posts)
+}
+
+// Post is the resolver for the post field.
+func (r *queryResolver) Post(ctx context.Context, id string) (*model.Post, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
+
+	post_id, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert post id to int: %w", err)
+	}
+
+	logging.FromContext(ctx).Debug("finding post", "post_id", post_id)
+
+	post, err := r.PostRepo.GetPostByID(ctx, post_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+	if !canViewPost(post, userID) {
+		return nil, fmt.Errorf("failed to get post: post not found")
+	}
+
+	user, err := r.UserRepo.GetUserByID(ctx, post.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	model_user := userToModel(user)
+
+	comments, err := r.CommentRepo.GetCommentsByPostID(
+		ctx,
+		post.ID,
+		10,
+		0,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+
+	var commentUsersIds []int
+	for _, comment := range comments {
+		commentUsersIds = append(commentUsersIds, comment.UserID)
+	}
+	comment_users, err := r.UserRepo.GetUsersByIDs(ctx, commentUsersIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	post_tags, err := r.TagRepo.GetTagsByPostIDs(ctx, []int{post.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	attachments, err := r.postAttachments(ctx, post.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	bookmark_counts, bookmarked, err := r.bookmarkStats(ctx, []int{post.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	var model_comments []*model.Comment
+	for _, comment := range comments {
+		currUserId := comment.UserID
+		var ParentId *string
+		if comment.ParentID == nil {
+			ParentId = nil
+		} else {
+			parentIdValue := strconv.Itoa(*comment.ParentID)
+			ParentId = &parentIdValue
+		}
+		model_comments = append(model_comments, &model.Comment{
+			ID:            strconv.Itoa(comment.ID),
+			Content:       comment.Content,
+			ContentFormat: model.ContentFormat(comment.ContentFormat),
+			User:          findUser(comment_users, currUserId),
+			ParentID:      ParentId,
+			PostID:        strconv.Itoa(comment.PostID),
+		})
+	}
+
+	model_post := model.Post{
+		ID:            strconv.Itoa(post.ID),
+		Title:         post.Title,
+		Content:       post.Content,
+		ContentFormat: model.ContentFormat(post.ContentFormat),
+		User:          model_user,
+		Commentable:   post.Commentable,
+		Comments:      model_comments,
+		Tags:          post_tags[post.ID],
+		Status:        model.PostStatus(post.Status),
+		PublishAt:     post.PublishAt,
+		Attachments:   attachments,
+		IsBookmarked:  bookmarked[post.ID],
+		BookmarkCount: int32(bookmark_counts[post.ID]),
+		CommentCount:  int32(post.CommentCount),
+		LastCommentAt: post.LastCommentAt,
+	}
+
+	return &model_post, nil
+}
+
+// Comments is the resolver for the comments field.
+func (r *queryResolver) Comments(ctx context.Context, limit *int32, offset *int32, postID string) ([]*model.Comment, error) {
+	_, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
+
+	post_id, err := strconv.Atoi(postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert post id to int: %w", err)
+	}
+
+	logging.FromContext(ctx).Debug("finding comments", "post_id", post_id)
+
+	comments, err := r.CommentRepo.GetCommentsByPostID(
 		ctx,
 		post_id,
 		int(*limit),
@@ -704,12 +1019,6 @@ func (r *queryResolver) Comments(ctx context.Context, limit *int32, offset *int3
 	var model_comments []*model.Comment
 	for _, comment := range comments {
 		currUserId := comment.UserID
-		var username string
-		for _, user := range comment_users {
-			if user.ID == currUserId {
-				username = user.Username
-			}
-		}
 		var ParentId *string
 		if comment.ParentID == nil {
 			ParentId = nil
@@ -718,64 +1027,555 @@ func (r *queryResolver) Comments(ctx context.Context, limit *int32, offset *int3
 			ParentId = &parentIdValue
 		}
 		model_comments = append(model_comments, &model.Comment{
-			ID:      strconv.Itoa(comment.ID),
-			Content: comment.Content,
-			User: &model.User{
-				ID:       strconv.Itoa(currUserId),
-				Username: username,
-			},
-			ParentID: ParentId,
-			PostID:   strconv.Itoa(comment.PostID),
+			ID:            strconv.Itoa(comment.ID),
+			Content:       comment.Content,
+			ContentFormat: model.ContentFormat(comment.ContentFormat),
+			User:          findUser(comment_users, currUserId),
+			ParentID:      ParentId,
+			PostID:        strconv.Itoa(comment.PostID),
 		})
 	}
 
 	return model_comments, nil
 }
 
-var commentChannel map[string]chan *model.Comment
-var commentChannelMu sync.Mutex
+// Tags is the resolver for the tags field.
+func (r *queryResolver) Tags(ctx context.Context, query string, first *int32) ([]*model.Tag, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
+
+	if *first <= 0 {
+		return nil, fmt.Errorf("first must be positive")
+	}
+
+	prefix := strings.ToLower(strings.Join(strings.Fields(query), " "))
+
+	tags, err := r.TagRepo.SearchTags(ctx, prefix, int(*first))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tags: %w", err)
+	}
+
+	followed, err := r.followedTagSet(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tagsToModel(tags, followed), nil
+}
+
+// Tag is the resolver for the tag field.
+func (r *queryResolver) Tag(ctx context.Context, name string) (*model.Tag, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
+
+	// тега с некорректным именем быть не может
+	tagName, err := normalizeTag(name)
+	if err != nil {
+		return nil, nil
+	}
+
+	tag, err := r.getTag(ctx, userID, tagName)
+	if errors.Is(err, errTagNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// FollowedTags is the resolver for the followedTags field.
+func (r *queryResolver) FollowedTags(ctx context.Context) ([]*model.Tag, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
 
-func init() {
-	commentChannel = map[string]chan *model.Comment{}
+	names, err := r.TagRepo.GetFollowedTags(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get followed tags: %w", err)
+	}
+
+	tags, err := r.TagRepo.GetTagsByNames(ctx, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	followed := make(map[string]bool, len(names))
+	for _, name := range names {
+		followed[name] = true
+	}
+
+	return tagsToModel(tags, followed), nil
+}
+
+// PostsByTag is the resolver for the postsByTag field.
+func (r *queryResolver) PostsByTag(ctx context.Context, tag string, first *int32, after *string) (*model.PostConnection, error) {
+	_, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
+
+	if *first <= 0 {
+		return nil, fmt.Errorf("first must be positive")
+	}
+
+	tagName, err := normalizeTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var afterId int
+	if after != nil {
+		afterId, err = strconv.Atoi(*after)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert cursor to int: %w", err)
+		}
+	}
+
+	logging.FromContext(ctx).Debug("finding posts by tag", "tag", tagName, "first", *first)
+
+	// берём на один больше, чтобы узнать, есть ли следующая страница
+	posts, err := r.TagRepo.GetPostsByTag(ctx, tagName, int(*first)+1, afterId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts: %w", err)
+	}
+	hasNextPage := len(posts) > int(*first)
+	if hasNextPage {
+		posts = posts[:*first]
+	}
+
+	model_posts, err := r.postsToModel(ctx, posts)
+	if err != nil {
+		return nil, err
+	}
+
+	pageInfo := model.PageInfo{HasNextPage: hasNextPage}
+	if len(model_posts) > 0 {
+		pageInfo.EndCursor = &model_posts[len(model_posts)-1].ID
+	}
+
+	return &model.PostConnection{
+		Nodes:    model_posts,
+		PageInfo: &pageInfo,
+	}, nil
+}
+
+// MyDrafts is the resolver for the myDrafts field.
+func (r *queryResolver) MyDrafts(ctx context.Context, first *int32, after *string) (*model.PostConnection, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
+
+	if *first <= 0 {
+		return nil, fmt.Errorf("first must be positive")
+	}
+
+	var afterId int
+	if after != nil {
+		var err error
+		afterId, err = strconv.Atoi(*after)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert cursor to int: %w", err)
+		}
+	}
+
+	// берём на один больше, чтобы узнать, есть ли следующая страница
+	posts, err := r.PostRepo.GetDraftsByUserId(ctx, userID, int(*first)+1, afterId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get drafts: %w", err)
+	}
+	hasNextPage := len(posts) > int(*first)
+	if hasNextPage {
+		posts = posts[:*first]
+	}
+
+	model_posts, err := r.postsToModel(ctx, posts)
+	if err != nil {
+		return nil, err
+	}
+
+	pageInfo := model.PageInfo{HasNextPage: hasNextPage}
+	if len(model_posts) > 0 {
+		pageInfo.EndCursor = &model_posts[len(model_posts)-1].ID
+	}
+
+	return &model.PostConnection{
+		Nodes:    model_posts,
+		PageInfo: &pageInfo,
+	}, nil
+}
+
+// Bookmarks is the resolver for the bookmarks field.
+func (r *queryResolver) Bookmarks(ctx context.Context, first *int32, after *string) (*model.PostConnection, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
+
+	if *first <= 0 {
+		return nil, fmt.Errorf("first must be positive")
+	}
+
+	var afterId int
+	if after != nil {
+		var err error
+		afterId, err = strconv.Atoi(*after)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert cursor to int: %w", err)
+		}
+	}
+
+	// берём на один больше, чтобы узнать, есть ли следующая страница
+	bookmarks, err := r.BookmarkRepo.GetBookmarks(ctx, userID, int(*first)+1, afterId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bookmarks: %w", err)
+	}
+	hasNextPage := len(bookmarks) > int(*first)
+	if hasNextPage {
+		bookmarks = bookmarks[:*first]
+	}
+
+	var posts []*repo_models.Post
+	for _, bookmark := range bookmarks {
+		posts = append(posts, bookmark.Post)
+	}
+	model_posts, err := r.postsToModel(ctx, posts)
+	if err != nil {
+		return nil, err
+	}
+
+	// курсор - id закладки, а не поста: список упорядочен по времени добавления
+	pageInfo := model.PageInfo{HasNextPage: hasNextPage}
+	if len(bookmarks) > 0 {
+		endCursor := strconv.Itoa(bookmarks[len(bookmarks)-1].ID)
+		pageInfo.EndCursor = &endCursor
+	}
+
+	return &model.PostConnection{
+		Nodes:    model_posts,
+		PageInfo: &pageInfo,
+	}, nil
+}
+
+// Feed is the resolver for the feed field.
+func (r *queryResolver) Feed(ctx context.Context, first *int32, after *string) (*model.PostConnection, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
+
+	if *first <= 0 {
+		return nil, fmt.Errorf("first must be positive")
+	}
+
+	var cursor *repo_models.FeedCursor
+	if after != nil {
+		var err error
+		cursor, err = parseFeedCursor(*after)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// берём на один больше, чтобы узнать, есть ли следующая страница
+	posts, err := r.FollowRepo.GetFeed(ctx, userID, int(*first)+1, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed: %w", err)
+	}
+	hasNextPage := len(posts) > int(*first)
+	if hasNextPage {
+		posts = posts[:*first]
+	}
+
+	model_posts, err := r.postsToModel(ctx, posts)
+	if err != nil {
+		return nil, err
+	}
+
+	pageInfo := model.PageInfo{HasNextPage: hasNextPage}
+	if len(posts) > 0 {
+		endCursor := feedCursor(posts[len(posts)-1])
+		pageInfo.EndCursor = &endCursor
+	}
+
+	return &model.PostConnection{
+		Nodes:    model_posts,
+		PageInfo: &pageInfo,
+	}, nil
+}
+
+// Notifications is the resolver for the notifications field.
+func (r *queryResolver) Notifications(ctx context.Context, first *int32, after *string, unreadOnly *bool) (*model.NotificationConnection, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
+
+	if *first <= 0 {
+		return nil, fmt.Errorf("first must be positive")
+	}
+
+	var afterId int
+	if after != nil {
+		var err error
+		afterId, err = strconv.Atoi(*after)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert cursor to int: %w", err)
+		}
+	}
+
+	// берём на одно больше, чтобы узнать, есть ли следующая страница
+	notifications, err := r.NotificationRepo.GetNotifications(ctx, userID, int(*first)+1, afterId, *unreadOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notifications: %w", err)
+	}
+	hasNextPage := len(notifications) > int(*first)
+	if hasNextPage {
+		notifications = notifications[:*first]
+	}
+
+	var actorIds []int
+	for _, notification := range notifications {
+		actorIds = append(actorIds, notification.ActorID)
+	}
+	actors, err := r.UserRepo.GetUsersByIDs(ctx, actorIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	model_notifications := make([]*model.Notification, 0, len(notifications))
+	for _, notification := range notifications {
+		model_notifications = append(model_notifications, notificationToModel(notification, findUser(actors, notification.ActorID)))
+	}
+
+	pageInfo := model.PageInfo{HasNextPage: hasNextPage}
+	if len(model_notifications) > 0 {
+		pageInfo.EndCursor = &model_notifications[len(model_notifications)-1].ID
+	}
+
+	return &model.NotificationConnection{
+		Nodes:    model_notifications,
+		PageInfo: &pageInfo,
+	}, nil
 }
 
 // NewComments is the resolver for the newComments field.
-func (r *subscriptionResolver) NewComments(ctx context.Context, postID string) (<-chan *model.Comment, error) {
+func (r *subscriptionResolver) NewComments(ctx context.Context, postID string, since *string) (<-chan *model.Comment, error) {
+	_, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
+	}
+
+	dbPostId, err := strconv.Atoi(postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert post id to int: %w", err)
+	}
+
+	var lastEventId int
+	if since != nil {
+		lastEventId, err = strconv.Atoi(*since)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert event id to int: %w", err)
+		}
+	}
+
+	logger := logging.FromContext(ctx).With("post_id", dbPostId)
+	logger.Info("subscribed to comments")
+
+	// подписываемся до чтения журнала, чтобы не потерять события, пришедшие во время досылки
+	live := r.CommentHub.Subscribe(dbPostId)
+
+	var missed []commentEvent
+	if since != nil {
+		events, err := r.CommentEventRepo.GetCommentEventsSince(ctx, dbPostId, lastEventId)
+		if err != nil {
+			r.CommentHub.Unsubscribe(dbPostId, live)
+			return nil, fmt.Errorf("failed to get missed comments: %w", err)
+		}
+		missed, err = r.commentEventsToModel(ctx, events)
+		if err != nil {
+			r.CommentHub.Unsubscribe(dbPostId, live)
+			return nil, err
+		}
+	}
+
+	out := make(chan *model.Comment, 1)
+	go func() {
+		defer close(out)
+		defer r.CommentHub.Unsubscribe(dbPostId, live)
+		defer logger.Info("comments subscription ended")
+
+		for _, event := range missed {
+			select {
+			case <-ctx.Done():
+				return
+			case out <- event.comment:
+			}
+		}
+
+		// события могут публиковаться не по порядку id, поэтому отбрасываем
+		// только то, что попало в досылку, а не всё с id меньше последнего отправленного
+		replayedUpTo := lastReplayedID(missed)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-live:
+				if event.id <= replayedUpTo {
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case out <- event.comment:
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// NotificationAdded is the resolver for the notificationAdded field.
+func (r *subscriptionResolver) NotificationAdded(ctx context.Context) (<-chan *model.Notification, error) {
+	// пользователь в контексте есть только после проверки токена в connection_init
 	userID, ok := auth.GetUserID(ctx)
 	if !ok {
 		return nil, errors.New("invalid user")
 	}
-	fmt.Printf("User %d subscribe to comments on post %s", userID, postID)
-	defer fmt.Printf("Subscribe to post %s by user %d ended", postID, userID)
 
-	commentChannelMu.Lock()
-	defer commentChannelMu.Unlock()
+	logging.FromContext(ctx).Info("subscribed to notifications")
+
+	live := r.NotificationHub.Subscribe(userID)
+
+	out := make(chan *model.Notification, 1)
+	go func() {
+		defer close(out)
+		defer r.NotificationHub.Unsubscribe(userID, live)
+		defer logging.FromContext(ctx).Info("notifications subscription ended")
+
+		metrics.NotificationSubscriptions.Inc()
+		defer metrics.NotificationSubscriptions.Dec()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case notification := <-live:
+				select {
+				case <-ctx.Done():
+					return
+				case out <- notification:
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
 
-	if ch, ok := commentChannel[postID]; ok {
-		return ch, nil
+// PostPublished is the resolver for the postPublished field.
+func (r *subscriptionResolver) PostPublished(ctx context.Context) (<-chan *model.Post, error) {
+	_, ok := auth.GetUserID(ctx)
+	if !ok {
+		return nil, errors.New("invalid user")
 	}
 
-	event := make(chan *model.Comment, 1)
+	logging.FromContext(ctx).Info("subscribed to published posts")
+
+	live := r.PostHub.Subscribe(allPostsKey)
+
+	out := make(chan *model.Post, 1)
 	go func() {
-		<-ctx.Done()
-		commentChannelMu.Lock()
-		defer commentChannelMu.Unlock()
-		close(event)
-		delete(commentChannel, postID)
+		defer close(out)
+		defer r.PostHub.Unsubscribe(allPostsKey, live)
+		defer logging.FromContext(ctx).Info("published posts subscription ended")
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case post := <-live:
+				select {
+				case <-ctx.Done():
+					return
+				case out <- post:
+				}
+			}
+		}
 	}()
-	commentChannel[postID] = event
-	return event, nil
+
+	return out, nil
+}
+
+// PostCount is the resolver for the postCount field.
+func (r *userResolver) PostCount(ctx context.Context, obj *model.User) (int32, error) {
+	userID, err := strconv.Atoi(obj.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert user id to int: %w", err)
+	}
+
+	count, err := r.loaders(ctx).postCounts.load(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count posts: %w", err)
+	}
+
+	return int32(count), nil
 }
 
+// CommentCount is the resolver for the commentCount field.
+func (r *userResolver) CommentCount(ctx context.Context, obj *model.User) (int32, error) {
+	userID, err := strconv.Atoi(obj.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert user id to int: %w", err)
+	}
+
+	count, err := r.loaders(ctx).commentCounts.load(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count comments: %w", err)
+	}
+
+	return int32(count), nil
+}
+
+// Followers is the resolver for the followers field.
+func (r *userResolver) Followers(ctx context.Context, obj *model.User, first *int32, after *string) (*model.UserConnection, error) {
+	return r.followConnection(ctx, obj, first, after, true)
+}
+
+// Following is the resolver for the following field.
+func (r *userResolver) Following(ctx context.Context, obj *model.User, first *int32, after *string) (*model.UserConnection, error) {
+	return r.followConnection(ctx, obj, first, after, false)
+}
+
+// Comment returns CommentResolver implementation.
+func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
+// Post returns PostResolver implementation.
+func (r *Resolver) Post() PostResolver { return &postResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
 // Subscription returns SubscriptionResolver implementation.
 func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
 
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type commentResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type postResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
@@ -0,0 +1,103 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/AntonCkya/ozon_habr/graph/model"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+const (
+	maxTagsPerPost = 5
+	maxTagLength   = 32
+)
+
+// errTagNotFound отличает отсутствующий тег от ошибок хранилища
+var errTagNotFound = errors.New("not found")
+
+// буквы и цифры плюс символы из названий вроде "c++", "c#", ".net", "machine learning"
+var tagRegexp = regexp.MustCompile(`^[\p{L}\p{N}.][\p{L}\p{N} ._+#-]*$`)
+
+// normalizeTag приводит тег к виду, в котором он хранится: нижний регистр, одиночные пробелы
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+	if tag == "" {
+		return "", fmt.Errorf("tag is empty")
+	}
+	if utf8.RuneCountInString(tag) > maxTagLength {
+		return "", fmt.Errorf("tag %q is too long", tag)
+	}
+	if !tagRegexp.MatchString(tag) {
+		return "", fmt.Errorf("tag %q contains invalid characters", tag)
+	}
+	return tag, nil
+}
+
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		normalized, err := normalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		result = append(result, normalized)
+	}
+	if len(result) > maxTagsPerPost {
+		return nil, fmt.Errorf("post can have at most %d tags", maxTagsPerPost)
+	}
+	return result, nil
+}
+
+// followedTagSet - теги, на которые подписан пользователь, для Tag.isFollowed
+func (r *Resolver) followedTagSet(ctx context.Context, userID int) (map[string]bool, error) {
+	names, err := r.TagRepo.GetFollowedTags(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get followed tags: %w", err)
+	}
+	followed := make(map[string]bool, len(names))
+	for _, name := range names {
+		followed[name] = true
+	}
+	return followed, nil
+}
+
+func tagsToModel(tags []*repo_models.Tag, followed map[string]bool) []*model.Tag {
+	model_tags := make([]*model.Tag, 0, len(tags))
+	for _, tag := range tags {
+		model_tags = append(model_tags, &model.Tag{
+			Name:          tag.Name,
+			PostCount:     int32(tag.PostCount),
+			FollowerCount: int32(tag.FollowerCount),
+			IsFollowed:    followed[tag.Name],
+		})
+	}
+	return model_tags
+}
+
+// getTag возвращает тег со счётчиками и отметкой подписки пользователя
+func (r *Resolver) getTag(ctx context.Context, userID int, name string) (*model.Tag, error) {
+	tags, err := r.TagRepo.GetTagsByNames(ctx, []string{name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("tag %q %w", name, errTagNotFound)
+	}
+
+	followed, err := r.followedTagSet(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tagsToModel(tags, followed)[0], nil
+}
@@ -0,0 +1,41 @@
+package graph
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		want    []string
+		wantErr bool
+	}{
+		{"lower case and spaces", []string{"  Machine   Learning "}, []string{"machine learning"}, false},
+		{"special characters", []string{"C++", "c#", ".NET", "go-kit"}, []string{"c++", "c#", ".net", "go-kit"}, false},
+		{"cyrillic", []string{"Базы данных"}, []string{"базы данных"}, false},
+		{"duplicates after normalization", []string{"Go", "go", " GO "}, []string{"go"}, false},
+		{"empty list", nil, []string{}, false},
+		{"empty tag", []string{"go", "   "}, nil, true},
+		{"invalid characters", []string{"go!"}, nil, true},
+		{"starts with a dash", []string{"-go"}, nil, true},
+		{"too long", []string{strings.Repeat("я", maxTagLength+1)}, nil, true},
+		{"max length", []string{strings.Repeat("я", maxTagLength)}, []string{strings.Repeat("я", maxTagLength)}, false},
+		{"too many tags", []string{"a", "b", "c", "d", "e", "f"}, nil, true},
+		{"duplicates don't count to the limit", []string{"a", "b", "c", "d", "e", "A"}, []string{"a", "b", "c", "d", "e"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeTags(tt.tags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeTags(%q) error = %v, wantErr %v", tt.tags, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeTags(%q) = %q, want %q", tt.tags, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/AntonCkya/ozon_habr/graph/model"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+func userToModel(user *repo_models.User) *model.User {
+	model_user := model.User{
+		ID:           strconv.Itoa(user.ID),
+		Username:     user.Username,
+		RegisteredAt: user.CreatedAt,
+	}
+	if user.DisplayName != "" {
+		model_user.DisplayName = &user.DisplayName
+	}
+	if user.Bio != "" {
+		model_user.Bio = &user.Bio
+	}
+	if user.AvatarURL != "" {
+		model_user.AvatarURL = &user.AvatarURL
+	}
+	return &model_user
+}
+
+// findUser ищет пользователя в результате GetUsersByIDs
+func findUser(users []*repo_models.User, id int) *model.User {
+	for _, user := range users {
+		if user.ID == id {
+			return userToModel(user)
+		}
+	}
+	return &model.User{ID: strconv.Itoa(id)}
+}
+
+func isHTTPURL(value string) bool {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// DeleteAccount удаляет пользователя вместе со всеми его данными. В pg это один DELETE:
+// посты, комментарии и остальное удаляет ON DELETE CASCADE в той же транзакции.
+// Mem-хранилища и кэши каскада не знают и чистятся следом, комментарии - до постов.
+func (r *Resolver) DeleteAccount(ctx context.Context, userID int) error {
+	// строки вложений удалит каскад, а файлы нужно удалить самим
+	attachments, err := r.AttachmentRepo.GetAttachmentsByUserID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get attachments: %w", err)
+	}
+
+	if err := r.UserRepo.DeleteUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	repos := []any{
+		r.CommentRepo,
+		r.PostRepo,
+		r.NotificationRepo,
+		r.TagRepo,
+		r.BookmarkRepo,
+		r.FollowRepo,
+		r.AttachmentRepo,
+	}
+	for _, repo := range repos {
+		if deleter, ok := repo.(UserDataDeleter); ok {
+			if err := deleter.DeleteUserData(ctx, userID); err != nil {
+				return fmt.Errorf("failed to delete user data: %w", err)
+			}
+		}
+	}
+	r.deleteAttachmentFiles(ctx, attachments)
+
+	return nil
+}
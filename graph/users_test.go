@@ -0,0 +1,85 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+func TestDeleteAccountMem(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemResolver()
+
+	deleted, err := r.UserRepo.CreateUser(ctx, "delete_me", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := r.UserRepo.CreateUser(ctx, "keep_me", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ownPost, _ := r.PostRepo.CreatePost(ctx, "own", "text", repo_models.ContentFormatPlain, deleted.ID, true, repo_models.PostStatusPublished, nil)
+	otherPost, _ := r.PostRepo.CreatePost(ctx, "other", "text", repo_models.ContentFormatPlain, other.ID, true, repo_models.PostStatusPublished, nil)
+
+	// комментарий другого пользователя к удаляемому посту и ответ на комментарий удаляемого
+	onOwnPost, _ := r.CommentRepo.CreateComment(ctx, "hi", repo_models.ContentFormatPlain, other.ID, ownPost.ID, -1)
+	ownComment, _ := r.CommentRepo.CreateComment(ctx, "mine", repo_models.ContentFormatPlain, deleted.ID, otherPost.ID, -1)
+	reply, _ := r.CommentRepo.CreateComment(ctx, "reply", repo_models.ContentFormatPlain, other.ID, otherPost.ID, ownComment.ID)
+	kept, _ := r.CommentRepo.CreateComment(ctx, "kept", repo_models.ContentFormatPlain, other.ID, otherPost.ID, -1)
+
+	r.NotificationRepo.CreateNotification(ctx, other.ID, deleted.ID, repo_models.NotificationCommentReply, otherPost.ID, ownComment.ID)
+	r.NotificationRepo.CreateNotification(ctx, deleted.ID, other.ID, repo_models.NotificationPostComment, ownPost.ID, onOwnPost.ID)
+	r.NotificationRepo.CreateNotification(ctx, other.ID, other.ID, repo_models.NotificationMention, otherPost.ID, kept.ID)
+	r.FollowRepo.FollowUser(ctx, other.ID, deleted.ID)
+	r.FollowRepo.FollowUser(ctx, deleted.ID, other.ID)
+
+	if err := r.DeleteAccount(ctx, deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.UserRepo.GetUserByID(ctx, deleted.ID); err == nil {
+		t.Error("user still exists")
+	}
+	if _, err := r.PostRepo.GetPostByID(ctx, ownPost.ID); err == nil {
+		t.Error("own post still exists")
+	}
+
+	comments, _ := r.CommentRepo.GetCommentsByPostIDs(ctx, []int{ownPost.ID, otherPost.ID})
+	if len(comments) != 1 || comments[0].ID != kept.ID {
+		t.Errorf("comments left = %v, want only %d", comments, kept.ID)
+	}
+	for _, id := range []int{onOwnPost.ID, ownComment.ID, reply.ID} {
+		if _, err := r.CommentRepo.GetCommentByID(ctx, id); err == nil {
+			t.Errorf("comment %d still exists", id)
+		}
+	}
+
+	events, _ := r.CommentEventRepo.GetCommentEventsSince(ctx, otherPost.ID, 0)
+	if len(events) != 1 || events[0].Comment.ID != kept.ID {
+		t.Errorf("comment events left = %d, want only comment %d", len(events), kept.ID)
+	}
+
+	notifications, _ := r.NotificationRepo.GetNotifications(ctx, other.ID, 10, 0, false)
+	if len(notifications) != 1 || notifications[0].CommentID != kept.ID {
+		t.Errorf("notifications left = %d, want only about comment %d", len(notifications), kept.ID)
+	}
+
+	for name, count := range map[string]func(context.Context, int) (int, error){
+		"followers": r.FollowRepo.CountFollowers,
+		"following": r.FollowRepo.CountFollowing,
+	} {
+		if n, _ := count(ctx, other.ID); n != 0 {
+			t.Errorf("%s of other user = %d, want 0", name, n)
+		}
+	}
+
+	post, err := r.PostRepo.GetPostByID(ctx, otherPost.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.CommentCount != 1 {
+		t.Errorf("comment count = %d, want 1", post.CommentCount)
+	}
+}
@@ -0,0 +1,105 @@
+package apq
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const errQueryNotAllowed = "PERSISTED_QUERY_NOT_ALLOWED"
+
+// Manifest - заранее зарегистрированные запросы, sha256 -> текст запроса.
+// Как кэш для APQ он только читается: новые запросы в него не попадают.
+type Manifest struct {
+	queries map[string]string
+}
+
+// LoadManifest читает манифест в формате Apollo
+// ({"operations": [{"id": "...", "body": "..."}]}) или простой объект {"<sha256>": "<query>"}.
+// Хэши пересчитываются по тексту запроса.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read persisted queries manifest: %w", err)
+	}
+
+	var apollo struct {
+		Operations []struct {
+			Body string `json:"body"`
+		} `json:"operations"`
+	}
+	var bodies []string
+	if err := json.Unmarshal(data, &apollo); err == nil && apollo.Operations != nil {
+		for _, operation := range apollo.Operations {
+			bodies = append(bodies, operation.Body)
+		}
+	} else {
+		var plain map[string]string
+		if err := json.Unmarshal(data, &plain); err != nil {
+			return nil, fmt.Errorf("failed to parse persisted queries manifest: %w", err)
+		}
+		for _, body := range plain {
+			bodies = append(bodies, body)
+		}
+	}
+
+	manifest := &Manifest{queries: make(map[string]string, len(bodies))}
+	for _, body := range bodies {
+		manifest.queries[Hash(body)] = body
+	}
+	return manifest, nil
+}
+
+func (m *Manifest) Len() int {
+	return len(m.queries)
+}
+
+func (m *Manifest) Get(ctx context.Context, key string) (string, bool) {
+	query, ok := m.queries[key]
+	return query, ok
+}
+
+func (m *Manifest) Add(ctx context.Context, key string, query string) {}
+
+func Hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// AllowList пропускает только запросы из манифеста.
+// Должен подключаться после AutomaticPersistedQuery, чтобы видеть уже подставленный текст запроса.
+type AllowList struct {
+	Manifest *Manifest
+}
+
+var _ interface {
+	graphql.OperationParameterMutator
+	graphql.HandlerExtension
+} = AllowList{}
+
+func (a AllowList) ExtensionName() string {
+	return "AllowList"
+}
+
+func (a AllowList) Validate(schema graphql.ExecutableSchema) error {
+	if a.Manifest == nil {
+		return fmt.Errorf("AllowList.Manifest can not be nil")
+	}
+	return nil
+}
+
+func (a AllowList) MutateOperationParameters(ctx context.Context, rawParams *graphql.RawParams) *gqlerror.Error {
+	if _, ok := a.Manifest.queries[Hash(rawParams.Query)]; !ok {
+		err := gqlerror.Errorf("query is not in the persisted queries allow-list")
+		errcode.Set(err, errQueryNotAllowed)
+		return err
+	}
+	return nil
+}
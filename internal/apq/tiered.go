@@ -0,0 +1,33 @@
+package apq
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Tiered - быстрый кэш (LRU) перед медленным общим хранилищем (postgres)
+type Tiered struct {
+	front graphql.Cache[string]
+	back  graphql.Cache[string]
+}
+
+func NewTiered(front graphql.Cache[string], back graphql.Cache[string]) *Tiered {
+	return &Tiered{front: front, back: back}
+}
+
+func (t *Tiered) Get(ctx context.Context, key string) (string, bool) {
+	if query, ok := t.front.Get(ctx, key); ok {
+		return query, true
+	}
+	query, ok := t.back.Get(ctx, key)
+	if ok {
+		t.front.Add(ctx, key, query)
+	}
+	return query, ok
+}
+
+func (t *Tiered) Add(ctx context.Context, key string, query string) {
+	t.front.Add(ctx, key, query)
+	t.back.Add(ctx, key, query)
+}
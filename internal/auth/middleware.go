@@ -2,74 +2,139 @@ package auth
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/99designs/gqlgen/graphql"
+	"github.com/AntonCkya/ozon_habr/internal/logging"
+	"github.com/AntonCkya/ozon_habr/internal/metrics"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+	"github.com/AntonCkya/ozon_habr/internal/tracing"
 	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type strkey string
 
 const key strkey = "userID"
 
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenRevoked = errors.New("token revoked")
+)
+
+type UserGetter interface {
+	GetUserByID(ctx context.Context, id int) (*repo_models.User, error)
+}
+
+// ValidateToken парсит токен и проверяет, что пользователь существует и токен не отозван сменой пароля
+func ValidateToken(ctx context.Context, users UserGetter, tokenString string) (*repo_models.User, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	user, err := users.GetUserByID(ctx, claims.UserID)
+	if errors.Is(err, repo_models.ErrUserNotFound) || errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenRevoked
+	}
+	if err != nil {
+		// недоступность базы - не повод разлогинивать клиента
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.TokenVersion != claims.TokenVersion {
+		return nil, ErrTokenRevoked
+	}
+
+	return user, nil
+}
+
+// IsAuthError отличает недействительный или отозванный токен от ошибки проверки (например, база недоступна)
+func IsAuthError(err error) bool {
+	return errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrTokenRevoked)
+}
+
+// браузер не даёт задать хэдеры вебсокету, поэтому токен проверяется в connection_init (WebsocketInit)
 func isWebSocketUpgrade(r *http.Request) bool {
-	// хак, потому что хэдеры не передаются в вебсокет в playground
 	return strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") &&
 		strings.ToLower(r.Header.Get("Upgrade")) == "websocket"
 }
 
-func Middleware(next http.Handler) http.Handler {
+func Middleware(users UserGetter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return middleware(users, next)
+	}
+}
+
+func middleware(users UserGetter, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if isWebSocketUpgrade(r) {
-			ctx := context.WithValue(r.Context(), key, 1)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			// соединение живёт долго, отдельный спан на него не открываем,
+			// спаны будут у каждой операции внутри
+			next.ServeHTTP(w, r.WithContext(tracing.Extract(r)))
 			return
 		}
 
+		ctx, span := tracing.StartHTTPSpan(r)
+		defer span.End()
+		if span.SpanContext().IsValid() {
+			ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("trace_id", span.SpanContext().TraceID().String()))
+		}
+		r = r.WithContext(ctx)
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
+			metrics.AuthFailures.WithLabelValues(metrics.AuthMissingHeader).Inc()
+			span.SetStatus(codes.Error, metrics.AuthMissingHeader)
 			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
+			metrics.AuthFailures.WithLabelValues(metrics.AuthInvalidHeader).Inc()
+			span.SetStatus(codes.Error, metrics.AuthInvalidHeader)
 			http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
 			return
 		}
 
 		tokenString := parts[1]
 
-		claims, err := ParseToken(tokenString)
+		user, err := ValidateToken(r.Context(), users, tokenString)
+		if err != nil && !IsAuthError(err) {
+			logging.FromContext(ctx).Error("failed to validate token", "error", err)
+			span.SetStatus(codes.Error, err.Error())
+			http.Error(w, "Failed to validate token", http.StatusServiceUnavailable)
+			return
+		}
 		if err != nil {
+			metrics.AuthFailures.WithLabelValues(metrics.AuthInvalidToken).Inc()
+			span.SetStatus(codes.Error, metrics.AuthInvalidToken)
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), key, claims.UserID)
+		span.SetAttributes(attribute.Int("user.id", user.ID))
+		ctx = WithUserID(ctx, user.ID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// WithUserID кладёт пользователя в контекст так же, как это делает Middleware
+func WithUserID(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, key, userID)
+}
+
 func GetUserID(ctx context.Context) (int, bool) {
 	userID, ok := ctx.Value(key).(int)
 	return userID, ok
 }
 
-func isSubscriptionOperation(ctx context.Context) bool {
-	// тоже хак
-	opCtx := graphql.GetOperationContext(ctx)
-	if opCtx == nil {
-		return false
-	}
-	return opCtx.Operation.Operation == "subscription"
-}
-
 func AuthMiddleware(ctx context.Context, obj any, next graphql.Resolver) (any, error) {
-	if isSubscriptionOperation(ctx) {
-		return next(ctx)
-	}
 	if _, ok := GetUserID(ctx); !ok {
 		return nil, &gqlerror.Error{
 			Message: "Access denied",
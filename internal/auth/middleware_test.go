@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+// stubUsers отдаёт пользователя с id 1 или заданную ошибку
+type stubUsers struct {
+	tokenVersion int
+	err          error
+}
+
+func (s stubUsers) GetUserByID(ctx context.Context, id int) (*repo_models.User, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if id != 1 {
+		return nil, repo_models.ErrUserNotFound
+	}
+	return &repo_models.User{ID: id, TokenVersion: s.tokenVersion}, nil
+}
+
+func TestValidateTokenAndMiddleware(t *testing.T) {
+	valid, err := GenerateToken(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknownUser, err := GenerateToken(2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbDown := errors.New("connection refused")
+
+	tests := []struct {
+		name       string
+		users      stubUsers
+		token      string
+		wantErr    error
+		wantAuth   bool
+		wantStatus int
+	}{
+		{"valid", stubUsers{}, valid, nil, false, http.StatusOK},
+		{"malformed", stubUsers{}, "not-a-token", ErrInvalidToken, true, http.StatusUnauthorized},
+		{"user deleted", stubUsers{}, unknownUser, ErrTokenRevoked, true, http.StatusUnauthorized},
+		{"password changed", stubUsers{tokenVersion: 1}, valid, ErrTokenRevoked, true, http.StatusUnauthorized},
+		{"database unavailable", stubUsers{err: dbDown}, valid, dbDown, false, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateToken(context.Background(), tt.users, tt.token)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("ValidateToken error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && IsAuthError(err) != tt.wantAuth {
+				t.Errorf("IsAuthError(%v) = %v, want %v", err, IsAuthError(err), tt.wantAuth)
+			}
+
+			handler := Middleware(tt.users)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, ok := GetUserID(r.Context()); !ok {
+					t.Error("user is not in the context")
+				}
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/query", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
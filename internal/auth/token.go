@@ -7,17 +7,19 @@ import (
 )
 
 type Claims struct {
-	UserID int `json:"user_id"`
+	UserID       int `json:"user_id"`
+	TokenVersion int `json:"token_version"`
 	jwt.RegisteredClaims
 }
 
 var jwtSecret = []byte("aboba_secret") // временно
 
-func GenerateToken(userID int) (string, error) {
+func GenerateToken(userID int, tokenVersion int) (string, error) {
 	expirationTime := time.Now().Add(4000 * time.Hour)
 
 	claims := &Claims{
-		UserID: userID,
+		UserID:       userID,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/AntonCkya/ozon_habr/internal/logging"
+	"github.com/AntonCkya/ozon_habr/internal/metrics"
+)
+
+// WebsocketInit авторизует вебсокет по токену из connection_init
+// и возвращает пользователя в payload connection_ack.
+// Без действительного токена соединение не принимается.
+func WebsocketInit(users UserGetter) transport.WebsocketInitFunc {
+	return func(ctx context.Context, initPayload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		authHeader := initPayload.Authorization()
+		if authHeader == "" {
+			metrics.AuthFailures.WithLabelValues(metrics.AuthMissingHeader).Inc()
+			return ctx, nil, errors.New("authorization is required in connection_init")
+		}
+
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		user, err := ValidateToken(ctx, users, tokenString)
+		if err != nil && !IsAuthError(err) {
+			logging.FromContext(ctx).Error("failed to validate token", "error", err)
+			return ctx, nil, errors.New("failed to validate token, try again later")
+		}
+		if err != nil {
+			metrics.AuthFailures.WithLabelValues(metrics.AuthInvalidToken).Inc()
+			return ctx, nil, errors.New("invalid token")
+		}
+		ctx = WithUserID(ctx, user.ID)
+
+		return ctx, &transport.InitPayload{
+			"user": map[string]any{
+				"id":       user.ID,
+				"username": user.Username,
+			},
+		}, nil
+	}
+}
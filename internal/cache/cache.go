@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Cache - хранилище для кэширующих репозиториев. Значения - байты (JSON),
+// чтобы можно было подключить внешний кэш (redis, memcached) без изменений в декораторах.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// ttl 0 - без срока жизни (запись может быть вытеснена)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+	Delete(ctx context.Context, keys ...string)
+}
+
+// списки нельзя точечно инвалидировать (ключей столько, сколько пар limit/offset),
+// поэтому в ключи входит поколение, а инвалидация просто меняет его.
+// Поколение уникально, так что вытесненное и созданное заново поколение не воскресит старые записи.
+type generations struct {
+	cache   Cache
+	counter atomic.Int64
+}
+
+func (g *generations) get(ctx context.Context, key string) string {
+	if value, ok := g.cache.Get(ctx, key); ok {
+		return string(value)
+	}
+	return g.bump(ctx, key)
+}
+
+func (g *generations) bump(ctx context.Context, key string) string {
+	value := strconv.FormatInt(time.Now().UnixNano(), 36) + "." + strconv.FormatInt(g.counter.Add(1), 36)
+	g.cache.Set(ctx, key, []byte(value), 0)
+	return value
+}
+
+func load[T any](ctx context.Context, c Cache, key string) (T, bool) {
+	var value T
+	data, ok := c.Get(ctx, key)
+	if !ok {
+		return value, false
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return value, false
+	}
+	return value, true
+}
+
+func store(ctx context.Context, c Cache, key string, value any, ttl time.Duration) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	c.Set(ctx, key, data, ttl)
+}
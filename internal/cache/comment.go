@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/graph"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+// общее поколение меняется, когда нельзя сказать, каких постов коснулось изменение
+const allCommentsGenerationKey = "comments:gen"
+
+func commentsGenerationKey(postID int) string {
+	return fmt.Sprintf("comments:gen:%d", postID)
+}
+
+// CommentRepository кэширует комментарии постов. Поколение ведётся на каждый пост,
+// так что новый комментарий сбрасывает кэш комментариев только своего поста
+// и сам этот пост, в котором лежат счётчики комментариев.
+type CommentRepository struct {
+	graph.CommentRepoInterface
+	cache       Cache
+	generations *generations
+	ttl         time.Duration
+}
+
+func NewCommentRepository(repo graph.CommentRepoInterface, cache Cache, ttl time.Duration) *CommentRepository {
+	return &CommentRepository{
+		CommentRepoInterface: repo,
+		cache:                cache,
+		generations:          &generations{cache: cache},
+		ttl:                  ttl,
+	}
+}
+
+func (r *CommentRepository) postKey(ctx context.Context, postID int) string {
+	return fmt.Sprintf("comments:%s:%d:%s",
+		r.generations.get(ctx, allCommentsGenerationKey),
+		postID,
+		r.generations.get(ctx, commentsGenerationKey(postID)),
+	)
+}
+
+func (r *CommentRepository) GetCommentsByPostID(ctx context.Context, postID int, limit int, offset int) ([]*repo_models.Comment, error) {
+	key := fmt.Sprintf("%s:%d:%d", r.postKey(ctx, postID), limit, offset)
+	if comments, ok := load[[]*repo_models.Comment](ctx, r.cache, key); ok {
+		return comments, nil
+	}
+
+	comments, err := r.CommentRepoInterface.GetCommentsByPostID(ctx, postID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	store(ctx, r.cache, key, comments, r.ttl)
+	return comments, nil
+}
+
+// GetCommentsByPostIDs кэширует комментарии каждого поста отдельно и догружает только промахи
+func (r *CommentRepository) GetCommentsByPostIDs(ctx context.Context, postIDs []int) ([]*repo_models.Comment, error) {
+	var result []*repo_models.Comment
+	var missedIDs []int
+	missedKeys := make(map[int]string)
+	for _, postID := range postIDs {
+		key := r.postKey(ctx, postID) + ":all"
+		if comments, ok := load[[]*repo_models.Comment](ctx, r.cache, key); ok {
+			result = append(result, comments...)
+			continue
+		}
+		missedIDs = append(missedIDs, postID)
+		missedKeys[postID] = key
+	}
+	if len(missedIDs) == 0 {
+		return result, nil
+	}
+
+	comments, err := r.CommentRepoInterface.GetCommentsByPostIDs(ctx, missedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byPost := make(map[int][]*repo_models.Comment, len(missedIDs))
+	for _, comment := range comments {
+		byPost[comment.PostID] = append(byPost[comment.PostID], comment)
+	}
+	for _, postID := range missedIDs {
+		// пустой список тоже кэшируем, у большинства постов комментариев нет
+		postComments := byPost[postID]
+		if postComments == nil {
+			postComments = []*repo_models.Comment{}
+		}
+		store(ctx, r.cache, missedKeys[postID], postComments, r.ttl)
+	}
+
+	return append(result, comments...), nil
+}
+
+func (r *CommentRepository) CreateComment(ctx context.Context, content string, contentFormat string, userID int, postID int, parentID int) (*repo_models.Comment, error) {
+	comment, err := r.CommentRepoInterface.CreateComment(ctx, content, contentFormat, userID, postID, parentID)
+	if err != nil {
+		return nil, err
+	}
+	r.generations.bump(ctx, commentsGenerationKey(postID))
+	r.generations.bump(ctx, postGenerationKey(postID))
+	return comment, nil
+}
+
+func (r *CommentRepository) UpdateComment(ctx context.Context, id int, content string, contentFormat string) (*repo_models.Comment, error) {
+	comment, err := r.CommentRepoInterface.UpdateComment(ctx, id, content, contentFormat)
+	if err != nil {
+		return nil, err
+	}
+	r.generations.bump(ctx, commentsGenerationKey(comment.PostID))
+	return comment, nil
+}
+
+func (r *CommentRepository) DeleteComment(ctx context.Context, id int) error {
+	comment, err := r.CommentRepoInterface.GetCommentByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := r.CommentRepoInterface.DeleteComment(ctx, id); err != nil {
+		return err
+	}
+	r.generations.bump(ctx, commentsGenerationKey(comment.PostID))
+	r.generations.bump(ctx, postGenerationKey(comment.PostID))
+	return nil
+}
+
+func (r *CommentRepository) DeleteUserData(ctx context.Context, userID int) error {
+	if deleter, ok := r.CommentRepoInterface.(graph.UserDataDeleter); ok {
+		if err := deleter.DeleteUserData(ctx, userID); err != nil {
+			return err
+		}
+	}
+	r.generations.bump(ctx, allCommentsGenerationKey)
+	return nil
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/graph"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+const postsGenerationKey = "posts:gen"
+
+// у каждого поста ещё своё поколение: его меняют комментарии, чтобы не сбрасывать весь кэш постов
+func postGenerationKey(id int) string {
+	return fmt.Sprintf("post:gen:%d", id)
+}
+
+// PostRepository кэширует чтение постов. Любое изменение постов меняет поколение,
+// поэтому закэшированные списки и отдельные посты перестают читаться сразу.
+// Комментарии меняют только поколение своего поста: счётчики комментариев в списках
+// могут отставать до ttl, а списки по обсуждаемости не кэшируются совсем.
+// Методы, которые не переопределены, идут напрямую в PostRepoInterface.
+type PostRepository struct {
+	graph.PostRepoInterface
+	cache       Cache
+	generations *generations
+	ttl         time.Duration
+}
+
+func NewPostRepository(repo graph.PostRepoInterface, cache Cache, ttl time.Duration) *PostRepository {
+	return &PostRepository{
+		PostRepoInterface: repo,
+		cache:             cache,
+		generations:       &generations{cache: cache},
+		ttl:               ttl,
+	}
+}
+
+func (r *PostRepository) GetPostByID(ctx context.Context, id int) (*repo_models.Post, error) {
+	key := fmt.Sprintf("post:%s:%d:%s",
+		r.generations.get(ctx, postsGenerationKey),
+		id,
+		r.generations.get(ctx, postGenerationKey(id)),
+	)
+	if post, ok := load[*repo_models.Post](ctx, r.cache, key); ok {
+		return post, nil
+	}
+
+	post, err := r.PostRepoInterface.GetPostByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	store(ctx, r.cache, key, post, r.ttl)
+	return post, nil
+}
+
+func (r *PostRepository) GetPosts(ctx context.Context, limit int, offset int, order string) ([]*repo_models.Post, error) {
+	// порядок по обсуждаемости меняет каждый комментарий
+	if order != "" {
+		return r.PostRepoInterface.GetPosts(ctx, limit, offset, order)
+	}
+
+	key := fmt.Sprintf("posts:%s:%d:%d:%s", r.generations.get(ctx, postsGenerationKey), limit, offset, order)
+	if posts, ok := load[[]*repo_models.Post](ctx, r.cache, key); ok {
+		return posts, nil
+	}
+
+	posts, err := r.PostRepoInterface.GetPosts(ctx, limit, offset, order)
+	if err != nil {
+		return nil, err
+	}
+	store(ctx, r.cache, key, posts, r.ttl)
+	return posts, nil
+}
+
+func (r *PostRepository) GetPostsByUserId(ctx context.Context, limit int, offset int, userId int) ([]*repo_models.Post, error) {
+	key := fmt.Sprintf("posts:%s:user:%d:%d:%d", r.generations.get(ctx, postsGenerationKey), userId, limit, offset)
+	if posts, ok := load[[]*repo_models.Post](ctx, r.cache, key); ok {
+		return posts, nil
+	}
+
+	posts, err := r.PostRepoInterface.GetPostsByUserId(ctx, limit, offset, userId)
+	if err != nil {
+		return nil, err
+	}
+	store(ctx, r.cache, key, posts, r.ttl)
+	return posts, nil
+}
+
+func (r *PostRepository) CreatePost(ctx context.Context, title string, content string, contentFormat string, userID int, commentable bool, status string, publishAt *time.Time) (*repo_models.Post, error) {
+	post, err := r.PostRepoInterface.CreatePost(ctx, title, content, contentFormat, userID, commentable, status, publishAt)
+	if err != nil {
+		return nil, err
+	}
+	r.generations.bump(ctx, postsGenerationKey)
+	return post, nil
+}
+
+func (r *PostRepository) UpdatePost(ctx context.Context, id int, title string, content string, contentFormat string, userID int, commentable bool, status string, publishAt *time.Time) (*repo_models.Post, error) {
+	post, err := r.PostRepoInterface.UpdatePost(ctx, id, title, content, contentFormat, userID, commentable, status, publishAt)
+	if err != nil {
+		return nil, err
+	}
+	r.generations.bump(ctx, postsGenerationKey)
+	return post, nil
+}
+
+func (r *PostRepository) PublishDuePosts(ctx context.Context, now time.Time) ([]*repo_models.Post, error) {
+	posts, err := r.PostRepoInterface.PublishDuePosts(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) > 0 {
+		r.generations.bump(ctx, postsGenerationKey)
+	}
+	return posts, nil
+}
+
+func (r *PostRepository) DeletePost(ctx context.Context, id int) error {
+	if err := r.PostRepoInterface.DeletePost(ctx, id); err != nil {
+		return err
+	}
+	r.generations.bump(ctx, postsGenerationKey)
+	// комментарии удаляются вместе с постом
+	r.generations.bump(ctx, commentsGenerationKey(id))
+	return nil
+}
+
+// DeleteUserData сбрасывает кэш после удаления аккаунта: в pg посты и комментарии
+// удаляет каскад, мимо обёртки, поэтому сбрасываем всё
+func (r *PostRepository) DeleteUserData(ctx context.Context, userID int) error {
+	if deleter, ok := r.PostRepoInterface.(graph.UserDataDeleter); ok {
+		if err := deleter.DeleteUserData(ctx, userID); err != nil {
+			return err
+		}
+	}
+	r.generations.bump(ctx, postsGenerationKey)
+	return nil
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config - настройки сервера, читаются из переменных окружения
+type Config struct {
+	Port string
+
+	// LogFormat "json" для продакшена, иначе текст
+	LogFormat string
+	LogLevel  string
+
+	// экспорт трейсов: "none", "stdout" или "otlp" (адрес в OTEL_EXPORTER_OTLP_ENDPOINT)
+	TracingExporter    string
+	TracingSampleRatio float64
+
+	// разрешённые Origin для CORS и вебсокетов, "*" - любые
+	AllowedOrigins []string
+
+	WSMaxMessageSize int64
+	WSInitTimeout    time.Duration
+
+	// дополнительный список утёкших паролей, по одному на строку
+	BreachedPasswordsFile string
+
+	// переопределение лимитов мутаций, записи вида "createComment=20/1m"
+	RateLimits []string
+
+	// ограничения запросов к /query
+	MaxQueryComplexity int
+	MaxQueryDepth      int
+	MaxListLimit       int
+	// сколько комментариев в среднем у поста, для оценки сложности Post.comments
+	CommentsPerPostEstimate int
+
+	// кэш automatic persisted queries: "memory" или "postgres" (только с -s p)
+	APQCache     string
+	APQCacheSize int
+	// если задан, выполняются только запросы из этого манифеста
+	PersistedQueriesManifest string
+
+	// как часто проверять запланированные посты
+	SchedulerInterval time.Duration
+
+	// каталог для загруженных файлов и адрес, по которому они раздаются
+	UploadDir     string
+	MediaURL      string
+	UploadMaxSize int64
+	// через сколько удалять вложения, не привязанные к посту
+	AttachmentTTL time.Duration
+
+	// кэш чтения постов и комментариев, CacheTTL 0 - выключен
+	CacheTTL  time.Duration
+	CacheSize int
+
+	// сколько ждать базу при старте
+	DBConnectTimeout time.Duration
+	DBDriver         string
+	DBMaxOpenConns   int
+	DBMaxIdleConns   int
+	DBConnMaxLife    time.Duration
+	DBConnMaxIdle    time.Duration
+	// таймаут одного запроса к базе, если у запроса нет более раннего дедлайна
+	DBQueryTimeout time.Duration
+	// реплики для чтения (host или host:port), пусто - всё идёт в основную базу
+	DBReplicaHosts []string
+	// сколько после своей записи пользователь читает с основной базы
+	DBStickyWindow time.Duration
+
+	HTTPReadHeaderTimeout time.Duration
+	HTTPReadTimeout       time.Duration
+	// на SSE и вебсокеты не действует
+	HTTPWriteTimeout time.Duration
+	HTTPIdleTimeout  time.Duration
+	// сколько ждать завершения запросов при остановке
+	ShutdownTimeout time.Duration
+}
+
+func Load() Config {
+	return Config{
+		Port:             getString("PORT", "8080"),
+		AllowedOrigins:   getList("ALLOWED_ORIGINS", []string{"*"}),
+		WSMaxMessageSize: int64(getInt("WS_MAX_MESSAGE_SIZE", 64*1024)),
+		WSInitTimeout:    getDuration("WS_INIT_TIMEOUT", 10*time.Second),
+
+		LogFormat: getString("LOG_FORMAT", "text"),
+		LogLevel:  getString("LOG_LEVEL", "info"),
+
+		TracingExporter:    getString("TRACING_EXPORTER", "none"),
+		TracingSampleRatio: getFloat("TRACING_SAMPLE_RATIO", 1),
+
+		BreachedPasswordsFile: getString("BREACHED_PASSWORDS_FILE", ""),
+		RateLimits:            getList("RATE_LIMITS", nil),
+
+		MaxQueryComplexity:      getInt("MAX_QUERY_COMPLEXITY", 5000),
+		MaxQueryDepth:           getInt("MAX_QUERY_DEPTH", 10),
+		MaxListLimit:            getInt("MAX_LIST_LIMIT", 100),
+		CommentsPerPostEstimate: getInt("COMPLEXITY_COMMENTS_PER_POST", 20),
+
+		APQCache:                 getString("APQ_CACHE", "memory"),
+		APQCacheSize:             getInt("APQ_CACHE_SIZE", 1000),
+		PersistedQueriesManifest: getString("PERSISTED_QUERIES_MANIFEST", ""),
+
+		SchedulerInterval: getDuration("SCHEDULER_INTERVAL", 30*time.Second),
+
+		UploadDir:     getString("UPLOAD_DIR", "./uploads"),
+		MediaURL:      getString("MEDIA_URL", "/media/"),
+		UploadMaxSize: int64(getInt("UPLOAD_MAX_SIZE", 10*1024*1024)),
+		AttachmentTTL: getDuration("ATTACHMENT_TTL", 24*time.Hour),
+
+		CacheTTL:  getDuration("CACHE_TTL", 30*time.Second),
+		CacheSize: getInt("CACHE_SIZE", 10000),
+
+		DBConnectTimeout: getDuration("DB_CONNECT_TIMEOUT", time.Minute),
+		DBDriver:         getString("DB_DRIVER", "postgres"),
+		DBMaxOpenConns:   getInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:   getInt("DB_MAX_IDLE_CONNS", 25),
+		DBConnMaxLife:    getDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdle:    getDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		DBQueryTimeout:   getDuration("DB_QUERY_TIMEOUT", 5*time.Second),
+		DBReplicaHosts:   getList("DB_REPLICA_HOSTS", nil),
+		DBStickyWindow:   getDuration("DB_STICKY_WINDOW", 5*time.Second),
+
+		HTTPReadHeaderTimeout: getDuration("HTTP_READ_HEADER_TIMEOUT", 10*time.Second),
+		HTTPReadTimeout:       getDuration("HTTP_READ_TIMEOUT", 30*time.Second),
+		HTTPWriteTimeout:      getDuration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		HTTPIdleTimeout:       getDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		ShutdownTimeout:       getDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+	}
+}
+
+func getString(key string, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+func getList(key string, def []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func getInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("invalid config value, using default", "key", key, "value", value, "default", def)
+		return def
+	}
+	return result
+}
+
+func getFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	result, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		slog.Warn("invalid config value, using default", "key", key, "value", value, "default", def)
+		return def
+	}
+	return result
+}
+
+func getDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	result, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("invalid config value, using default", "key", key, "value", value, "default", def)
+		return def
+	}
+	return result
+}
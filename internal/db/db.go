@@ -1,13 +1,28 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
+	"github.com/XSAM/otelsql"
+	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// SchemaVersion - версия схемы, которую ожидает код. Должна совпадать с последней записью
+// schema_migrations в migrations/init.sql, иначе /readyz отвечает 503.
+const SchemaVersion = 10
+
+// ключ advisory lock, под которым применяются миграции: несколько копий сервиса,
+// стартующих одновременно, выполняют скрипт по очереди
+const migrationLockKey = 7243001
+
 type DBConfig struct {
 	Host     string
 	Port     string
@@ -15,6 +30,14 @@ type DBConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+
+	// драйвер database/sql: "postgres" (lib/pq) или "pgx"
+	Driver string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
 }
 
 func InitDB(cfg DBConfig) (*sql.DB, error) {
@@ -23,16 +46,100 @@ func InitDB(cfg DBConfig) (*sql.DB, error) {
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
 	)
 
-	db, err := sql.Open("postgres", uri)
+	// каждый запрос - дочерний спан текущего трейса; запросы вне трейса не пишутся
+	driverName := cfg.Driver
+	if driverName == "" {
+		driverName = "postgres"
+	}
+
+	db, err := otelsql.Open(driverName, uri,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitRows:             true,
+			OmitConnResetSession: true,
+			SpanFilter: func(ctx context.Context, method otelsql.Method, query string, args []driver.NamedValue) bool {
+				return trace.SpanFromContext(ctx).SpanContext().IsValid()
+			},
+		}),
+	)
 	if err != nil {
 		return nil, err
 	}
 
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
 	return db, nil
 }
 
+// Connect открывает пул и ждёт, пока база ответит на ping,
+// повторяя попытки с экспоненциальной задержкой до истечения timeout
+func Connect(ctx context.Context, cfg DBConfig, timeout time.Duration) (*sql.DB, error) {
+	db, err := InitDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delay := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return db, nil
+		}
+		slog.Warn("database is not ready, retrying", "attempt", attempt, "delay", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, 10*time.Second)
+	}
+}
+
+// Migrate применяет идемпотентный скрипт схемы в одной транзакции.
+// Скрипт выполняется целиком при каждом старте, так что база, созданная старой версией,
+// доводится до текущей без ручных шагов.
+func Migrate(ctx context.Context, db *sql.DB, script string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1);", migrationLockKey); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migrations: %w", err)
+	}
+	return nil
+}
+
+// CheckSchema проверяет, что миграции применены до версии, которую ожидает код
+func CheckSchema(ctx context.Context, db *sql.DB) error {
+	var version int
+	err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations;").Scan(&version)
+	if err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+	if version < SchemaVersion {
+		return fmt.Errorf("schema version %d is older than required %d", version, SchemaVersion)
+	}
+	return nil
+}
+
 func CloseDB(db *sql.DB) {
 	if err := db.Close(); err != nil {
-		log.Printf("Error closing database: %v", err)
+		slog.Error("failed to close database", "error", err)
 	}
 }
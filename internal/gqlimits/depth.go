@@ -0,0 +1,73 @@
+package gqlimits
+
+import (
+	"context"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const errDepthLimit = "DEPTH_LIMIT_EXCEEDED"
+
+// DepthLimit отклоняет запросы, в которых поля вложены глубже MaxDepth
+type DepthLimit struct {
+	MaxDepth int
+}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = DepthLimit{}
+
+func (d DepthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+func (d DepthLimit) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (d DepthLimit) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	op := opCtx.Doc.Operations.ForName(opCtx.OperationName)
+	if op == nil {
+		return nil
+	}
+
+	if depth := selectionDepth(op.SelectionSet, map[string]bool{}); depth > d.MaxDepth {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, d.MaxDepth)
+		errcode.Set(err, errDepthLimit)
+		return err
+	}
+	return nil
+}
+
+func selectionDepth(selectionSet ast.SelectionSet, visited map[string]bool) int {
+	maxDepth := 0
+	for _, selection := range selectionSet {
+		depth := 0
+		switch selection := selection.(type) {
+		case *ast.Field:
+			// интроспекция (GraphiQL, кодогенераторы) заведомо глубокая, её не считаем
+			if strings.HasPrefix(selection.Name, "__") {
+				continue
+			}
+			depth = 1 + selectionDepth(selection.SelectionSet, visited)
+		case *ast.InlineFragment:
+			depth = selectionDepth(selection.SelectionSet, visited)
+		case *ast.FragmentSpread:
+			if visited[selection.Name] || selection.Definition == nil {
+				continue
+			}
+			visited[selection.Name] = true
+			depth = selectionDepth(selection.Definition.SelectionSet, visited)
+			delete(visited, selection.Name)
+		}
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+	}
+	return maxDepth
+}
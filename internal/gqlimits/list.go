@@ -0,0 +1,102 @@
+package gqlimits
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const errListLimit = "LIMIT_EXCEEDED"
+
+// аргументы, задающие размер страницы
+var listArguments = []string{"limit", "first"}
+
+// ListLimit отклоняет запросы, где размер страницы больше MaxSize или отрицательный
+type ListLimit struct {
+	MaxSize int
+}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = ListLimit{}
+
+func (l ListLimit) ExtensionName() string {
+	return "ListLimit"
+}
+
+func (l ListLimit) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (l ListLimit) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	op := opCtx.Doc.Operations.ForName(opCtx.OperationName)
+	if op == nil {
+		return nil
+	}
+	return l.checkSelectionSet(op.SelectionSet, opCtx.Variables, map[string]bool{})
+}
+
+func (l ListLimit) checkSelectionSet(selectionSet ast.SelectionSet, variables map[string]any, visited map[string]bool) *gqlerror.Error {
+	for _, selection := range selectionSet {
+		var err *gqlerror.Error
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if err = l.checkField(selection, variables); err == nil {
+				err = l.checkSelectionSet(selection.SelectionSet, variables, visited)
+			}
+		case *ast.InlineFragment:
+			err = l.checkSelectionSet(selection.SelectionSet, variables, visited)
+		case *ast.FragmentSpread:
+			if visited[selection.Name] || selection.Definition == nil {
+				continue
+			}
+			visited[selection.Name] = true
+			err = l.checkSelectionSet(selection.Definition.SelectionSet, variables, visited)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l ListLimit) checkField(field *ast.Field, variables map[string]any) *gqlerror.Error {
+	if field.Definition == nil {
+		return nil
+	}
+	args := field.ArgumentMap(variables)
+	for _, name := range listArguments {
+		size, ok := toInt(args[name])
+		if !ok {
+			continue
+		}
+		if size < 0 || size > l.MaxSize {
+			err := gqlerror.ErrorPosf(field.Position, "%s.%s must be between 0 and %d, got %d", field.Name, name, l.MaxSize, size)
+			errcode.Set(err, errListLimit)
+			return err
+		}
+	}
+	return nil
+}
+
+func toInt(value any) (int, bool) {
+	switch value := value.(type) {
+	case int:
+		return value, true
+	case int32:
+		return int(value), true
+	case int64:
+		return int(value), true
+	case float64:
+		return int(value), true
+	case json.Number:
+		result, err := value.Int64()
+		return int(result), err == nil
+	}
+	return 0, false
+}
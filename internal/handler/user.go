@@ -1,20 +1,43 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/AntonCkya/ozon_habr/graph"
 	"github.com/AntonCkya/ozon_habr/internal/auth"
+	"github.com/AntonCkya/ozon_habr/internal/limiter"
+	"github.com/AntonCkya/ozon_habr/internal/logging"
+	"github.com/AntonCkya/ozon_habr/internal/metrics"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+	"github.com/AntonCkya/ozon_habr/internal/validation"
 )
 
+// AccountDeleter удаляет аккаунт со всеми данными, реализует graph.Resolver
+type AccountDeleter interface {
+	DeleteAccount(ctx context.Context, userID int) error
+}
+
 type AuthHandler struct {
-	userRepo graph.UserRepoInterface
+	userRepo  graph.UserRepoInterface
+	accounts  AccountDeleter
+	validator *validation.Validator
+	limiter   *limiter.LoginLimiter
 }
 
-func NewAuthHandler(userRepo graph.UserRepoInterface) *AuthHandler {
-	return &AuthHandler{userRepo: userRepo}
+func NewAuthHandler(userRepo graph.UserRepoInterface, accounts AccountDeleter, validator *validation.Validator, loginLimiter *limiter.LoginLimiter) *AuthHandler {
+	return &AuthHandler{
+		userRepo:  userRepo,
+		accounts:  accounts,
+		validator: validator,
+		limiter:   loginLimiter,
+	}
 }
 
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -28,13 +51,27 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	input.Username = validation.NormalizeUsername(input.Username)
+
+	errs := validation.FieldErrors{}
+	h.validator.ValidateUsername(input.Username, errs)
+	h.validator.ValidatePassword(input.Password, input.Username, errs)
+	if len(errs) > 0 {
+		writeFieldErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+
 	user, err := h.userRepo.CreateUser(r.Context(), input.Username, input.Password)
+	if errors.Is(err, repo_models.ErrUsernameTaken) {
+		writeFieldErrors(w, http.StatusConflict, validation.FieldErrors{"username": {err.Error()}})
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	token, err := auth.GenerateToken(user.ID)
+	token, err := auth.GenerateToken(user.ID, user.TokenVersion)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -63,18 +100,41 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userRepo.GetUserByUsername(r.Context(), input.Username)
+	username := validation.NormalizeUsername(input.Username)
+	ip := clientIP(r)
+
+	retryAfter, err := h.limiter.Allow(r.Context(), username, ip)
 	if err != nil {
-		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if retryAfter > 0 {
+		metrics.AuthFailures.WithLabelValues(metrics.AuthLoginRateLimited).Inc()
+		logging.FromContext(r.Context()).Warn("security event: login blocked", "username", username, "ip", ip, "retry_after", retryAfter)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, "too many login attempts", http.StatusTooManyRequests)
 		return
 	}
 
-	if !user.CheckPassword(input.Password) {
+	// несуществующий пользователь и неверный пароль считаются одинаково,
+	// чтобы по лимитеру нельзя было перебирать имена
+	user, err := h.userRepo.GetUserByUsername(r.Context(), username)
+	if err != nil || !user.CheckPassword(input.Password) {
+		metrics.AuthFailures.WithLabelValues(metrics.AuthInvalidCredentials).Inc()
+		if err := h.limiter.Failure(r.Context(), username, ip); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
-	token, err := auth.GenerateToken(user.ID)
+	if err := h.limiter.Success(r.Context(), username); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	token, err := auth.GenerateToken(user.ID, user.TokenVersion)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -93,32 +153,113 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+	user, ok := h.authenticate(w, r)
+	if !ok {
 		return
 	}
 
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+	response := map[string]any{
+		"user": map[string]any{
+			"id":       user.ID,
+			"username": user.Username,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ChangePassword меняет пароль и отзывает все выданные ранее токены, в ответе новый токен
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.authenticate(w, r)
+	if !ok {
 		return
 	}
 
-	tokenString := parts[1]
+	var input struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !user.CheckPassword(input.OldPassword) {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
 
-	claims, err := auth.ParseToken(tokenString)
+	errs := validation.FieldErrors{}
+	h.validator.ValidatePassword(input.NewPassword, user.Username, errs)
+	if len(errs) > 0 {
+		writeFieldErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+
+	user, err := h.userRepo.UpdatePassword(r.Context(), user.ID, input.NewPassword)
 	if err != nil {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	token, err := auth.GenerateToken(user.ID, user.TokenVersion)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("password changed", "user_id", user.ID)
+
+	response := map[string]any{
+		"token": token,
+		"user": map[string]any{
+			"id":       user.ID,
+			"username": user.Username,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *AuthHandler) ChangeUsername(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Username string `json:"username"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	user, err := h.userRepo.GetUserByID(r.Context(), claims.UserID)
+	input.Username = validation.NormalizeUsername(input.Username)
+
+	errs := validation.FieldErrors{}
+	h.validator.ValidateUsername(input.Username, errs)
+	if len(errs) > 0 {
+		writeFieldErrors(w, http.StatusUnprocessableEntity, errs)
+		return
+	}
+
+	user, err := h.userRepo.UpdateUsername(r.Context(), user.ID, input.Username)
+	if errors.Is(err, repo_models.ErrUsernameTaken) {
+		writeFieldErrors(w, http.StatusConflict, validation.FieldErrors{"username": {err.Error()}})
+		return
+	}
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	logging.FromContext(r.Context()).Info("username changed", "user_id", user.ID)
+
 	response := map[string]any{
 		"user": map[string]any{
 			"id":       user.ID,
@@ -129,3 +270,100 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+const (
+	// посты и комментарии остаются, но автор становится deleted_<id>
+	DeleteModeAnonymize = "anonymize"
+	// посты и комментарии удаляются вместе с аккаунтом
+	DeleteModeCascade = "cascade"
+)
+
+func (h *AuthHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Password string `json:"password"`
+		Mode     string `json:"mode"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !user.CheckPassword(input.Password) {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	switch input.Mode {
+	case DeleteModeAnonymize:
+		if err := h.userRepo.AnonymizeUser(r.Context(), user.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case DeleteModeCascade:
+		if err := h.accounts.DeleteAccount(r.Context(), user.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "mode must be 'anonymize' or 'cascade'", http.StatusBadRequest)
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("account deleted", "user_id", user.ID, "mode", input.Mode)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) authenticate(w http.ResponseWriter, r *http.Request) (*repo_models.User, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		metrics.AuthFailures.WithLabelValues(metrics.AuthMissingHeader).Inc()
+		http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		metrics.AuthFailures.WithLabelValues(metrics.AuthInvalidHeader).Inc()
+		http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	tokenString := parts[1]
+
+	user, err := auth.ValidateToken(r.Context(), h.userRepo, tokenString)
+	if err != nil && !auth.IsAuthError(err) {
+		logging.FromContext(r.Context()).Error("failed to validate token", "error", err)
+		http.Error(w, "Failed to validate token", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if err != nil {
+		metrics.AuthFailures.WithLabelValues(metrics.AuthInvalidToken).Inc()
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	return user, true
+}
+
+func writeFieldErrors(w http.ResponseWriter, status int, errs validation.FieldErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"errors": errs,
+	})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check возвращает ошибку, если зависимость не готова
+type Check func(ctx context.Context) error
+
+const checkTimeout = 2 * time.Second
+
+type Checker struct {
+	mu           sync.RWMutex
+	names        []string
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+func New() *Checker {
+	return &Checker{checks: make(map[string]Check)}
+}
+
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.names = append(c.names, name)
+	c.checks[name] = check
+}
+
+// SetShuttingDown переводит /readyz в 503, чтобы балансировщик перестал слать новые запросы
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Healthz - процесс жив и отвечает
+func (c *Checker) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok"))
+}
+
+// Readyz - сервер готов принимать запросы: все проверки прошли и он не останавливается
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	ready := true
+	results := make(map[string]string)
+
+	if c.shuttingDown.Load() {
+		ready = false
+		results["shutdown"] = "shutting down"
+	}
+
+	c.mu.RLock()
+	for _, name := range c.names {
+		if err := c.checks[name](ctx); err != nil {
+			ready = false
+			results[name] = err.Error()
+		} else {
+			results[name] = "ok"
+		}
+	}
+	c.mu.RUnlock()
+
+	status := "ok"
+	code := http.StatusOK
+	if !ready {
+		status = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"status": status,
+		"checks": results,
+	})
+}
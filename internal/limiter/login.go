@@ -0,0 +1,168 @@
+package limiter
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/internal/logging"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+// Store хранит состояние попыток входа. Реализации: mem_repository и pg_repository.
+type Store interface {
+	GetLoginAttempts(ctx context.Context, key string) (*repo_models.LoginAttempts, error)
+	// AddLoginFailure атомарно увеличивает счётчик неудач и возвращает новое состояние.
+	// Если последняя неудача была раньше windowStart, счёт начинается заново.
+	AddLoginFailure(ctx context.Context, key string, now time.Time, windowStart time.Time) (*repo_models.LoginAttempts, error)
+	// LockLoginAttempts продлевает блокировку до until (уже более долгую не сокращает)
+	LockLoginAttempts(ctx context.Context, key string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, key string) error
+	// DeleteStaleLoginAttempts удаляет записи без неудач после before и без действующей блокировки
+	DeleteStaleLoginAttempts(ctx context.Context, before time.Time, now time.Time) (int, error)
+}
+
+type Policy struct {
+	// сколько неудачных попыток подряд разрешено без задержки
+	FreeAttempts int
+	// задержка после первой попытки сверх бесплатных, дальше удваивается
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// после стольких неудач ключ блокируется на LockoutDuration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	// неудачи старше окна забываются
+	Window time.Duration
+}
+
+var DefaultUserPolicy = Policy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        5 * time.Minute,
+	LockoutAfter:    10,
+	LockoutDuration: 15 * time.Minute,
+	Window:          time.Hour,
+}
+
+// с одного IP (NAT, офис) логинится много людей, поэтому лимит мягче
+var DefaultIPPolicy = Policy{
+	FreeAttempts:    20,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutAfter:    100,
+	LockoutDuration: 15 * time.Minute,
+	Window:          time.Hour,
+}
+
+type LoginLimiter struct {
+	store      Store
+	userPolicy Policy
+	ipPolicy   Policy
+	now        func() time.Time
+}
+
+func NewLoginLimiter(store Store, userPolicy Policy, ipPolicy Policy) *LoginLimiter {
+	return &LoginLimiter{
+		store:      store,
+		userPolicy: userPolicy,
+		ipPolicy:   ipPolicy,
+		now:        time.Now,
+	}
+}
+
+func userKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Allow возвращает, сколько ещё ждать до следующей попытки (0 - можно пробовать)
+func (l *LoginLimiter) Allow(ctx context.Context, username string, ip string) (time.Duration, error) {
+	var retryAfter time.Duration
+	for _, key := range []string{userKey(username), ipKey(ip)} {
+		attempts, err := l.store.GetLoginAttempts(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if attempts == nil {
+			continue
+		}
+		if wait := attempts.LockedUntil.Sub(l.now()); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	return retryAfter, nil
+}
+
+func (l *LoginLimiter) Failure(ctx context.Context, username string, ip string) error {
+	if err := l.failure(ctx, userKey(username), l.userPolicy, username, ip); err != nil {
+		return err
+	}
+	return l.failure(ctx, ipKey(ip), l.ipPolicy, username, ip)
+}
+
+// Success сбрасывает счётчик пользователя. Счётчик IP не сбрасываем:
+// иначе перебор можно обнулять, периодически входя в свой аккаунт.
+func (l *LoginLimiter) Success(ctx context.Context, username string) error {
+	return l.store.ResetLoginAttempts(ctx, userKey(username))
+}
+
+func (l *LoginLimiter) failure(ctx context.Context, key string, policy Policy, username string, ip string) error {
+	now := l.now()
+
+	attempts, err := l.store.AddLoginFailure(ctx, key, now, now.Add(-policy.Window))
+	if err != nil {
+		return err
+	}
+
+	var lockedUntil time.Time
+	switch {
+	case attempts.Failures >= policy.LockoutAfter:
+		lockedUntil = now.Add(policy.LockoutDuration)
+		if attempts.Failures == policy.LockoutAfter {
+			logging.FromContext(ctx).Warn("security event: login lockout",
+				"key", key, "username", username, "ip", ip, "failures", attempts.Failures, "locked_until", lockedUntil)
+		}
+	case attempts.Failures > policy.FreeAttempts:
+		delay := policy.BaseDelay << (attempts.Failures - policy.FreeAttempts - 1)
+		if delay > policy.MaxDelay || delay <= 0 {
+			delay = policy.MaxDelay
+		}
+		lockedUntil = now.Add(delay)
+		logging.FromContext(ctx).Warn("security event: repeated login failures",
+			"key", key, "username", username, "ip", ip, "failures", attempts.Failures, "backoff", delay)
+	default:
+		return nil
+	}
+
+	return l.store.LockLoginAttempts(ctx, key, lockedUntil)
+}
+
+// Cleanup раз в interval удаляет забытые записи, чтобы перебор выдуманных имён
+// не копил их бесконечно. Работает до отмены ctx.
+func (l *LoginLimiter) Cleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := l.now()
+		window := max(l.userPolicy.Window, l.ipPolicy.Window)
+		deleted, err := l.store.DeleteStaleLoginAttempts(ctx, now.Add(-window), now)
+		if err != nil {
+			slog.Error("failed to delete stale login attempts", "error", err)
+			continue
+		}
+		if deleted > 0 {
+			slog.Info("stale login attempts deleted", "count", deleted)
+		}
+	}
+}
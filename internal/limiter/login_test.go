@@ -0,0 +1,143 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/internal/mem_repository"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:    2,
+	BaseDelay:       time.Second,
+	MaxDelay:        4 * time.Second,
+	LockoutAfter:    6,
+	LockoutDuration: time.Minute,
+	Window:          time.Hour,
+}
+
+func newTestLimiter(now *time.Time) (*LoginLimiter, *mem_repository.LoginAttemptRepository) {
+	store := mem_repository.NewLoginAttemptRepository()
+	l := NewLoginLimiter(store, testPolicy, Policy{FreeAttempts: 1000, LockoutAfter: 1000, Window: time.Hour})
+	l.now = func() time.Time { return *now }
+	return l, store
+}
+
+func TestLoginLimiterBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: 0},
+		{failures: 2, want: 0},
+		{failures: 3, want: time.Second},
+		{failures: 4, want: 2 * time.Second},
+		{failures: 5, want: 4 * time.Second},
+		{failures: 6, want: time.Minute},
+		{failures: 20, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		l, _ := newTestLimiter(&now)
+		ctx := context.Background()
+
+		for range tt.failures {
+			if err := l.Failure(ctx, "alice", "10.0.0.1"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := l.Allow(ctx, "Alice", "10.0.0.2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("after %d failures Allow = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginLimiterWindowAndReset(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l, _ := newTestLimiter(&now)
+	ctx := context.Background()
+
+	for range 3 {
+		l.Failure(ctx, "alice", "10.0.0.1")
+	}
+	if wait, _ := l.Allow(ctx, "alice", "10.0.0.1"); wait != time.Second {
+		t.Fatalf("Allow = %v, want 1s", wait)
+	}
+
+	// неудачи старше окна забываются
+	now = now.Add(2 * time.Hour)
+	l.Failure(ctx, "alice", "10.0.0.1")
+	if wait, _ := l.Allow(ctx, "alice", "10.0.0.1"); wait != 0 {
+		t.Fatalf("Allow after window = %v, want 0", wait)
+	}
+
+	for range 5 {
+		l.Failure(ctx, "alice", "10.0.0.1")
+	}
+	l.Success(ctx, "alice")
+	if wait, _ := l.Allow(ctx, "alice", "10.0.0.1"); wait != 0 {
+		t.Fatalf("Allow after success = %v, want 0", wait)
+	}
+}
+
+func TestLoginLimiterConcurrentFailures(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l, store := newTestLimiter(&now)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Failure(ctx, "alice", "10.0.0.1")
+		}()
+	}
+	wg.Wait()
+
+	attempts, err := store.GetLoginAttempts(ctx, userKey("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts.Failures != 50 {
+		t.Errorf("failures = %d, want 50", attempts.Failures)
+	}
+	if want := now.Add(testPolicy.LockoutDuration); !attempts.LockedUntil.Equal(want) {
+		t.Errorf("locked until %v, want %v", attempts.LockedUntil, want)
+	}
+}
+
+func TestDeleteStaleLoginAttempts(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l, store := newTestLimiter(&now)
+	ctx := context.Background()
+
+	for range 10 {
+		l.Failure(ctx, "locked", "10.0.0.1")
+	}
+	l.Failure(ctx, "bob", "10.0.0.1")
+	now = now.Add(30 * time.Minute)
+	l.Failure(ctx, "recent", "10.0.0.2")
+
+	deleted, err := store.DeleteStaleLoginAttempts(ctx, now.Add(-10*time.Minute), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// удалены user:locked, user:bob и ip:10.0.0.1; user:recent и ip:10.0.0.2 остались
+	if deleted != 3 {
+		t.Errorf("deleted = %d, want 3", deleted)
+	}
+	for key, want := range map[string]bool{userKey("bob"): false, userKey("recent"): true, ipKey("10.0.0.2"): true} {
+		attempts, _ := store.GetLoginAttempts(ctx, key)
+		if (attempts != nil) != want {
+			t.Errorf("%s exists = %v, want %v", key, attempts != nil, want)
+		}
+	}
+}
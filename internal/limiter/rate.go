@@ -0,0 +1,150 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/AntonCkya/ozon_habr/internal/auth"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Rate - не больше Limit вызовов за Period
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParseRates разбирает записи вида "createComment=20/1m"
+func ParseRates(entries []string) map[string]Rate {
+	rates := make(map[string]Rate)
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			slog.Warn("invalid rate limit, expected field=limit/period", "value", entry)
+			continue
+		}
+		rate, err := parseRate(value)
+		if err != nil {
+			slog.Warn("invalid rate limit", "value", entry, "error", err)
+			continue
+		}
+		rates[strings.TrimSpace(name)] = rate
+	}
+	return rates
+}
+
+func parseRate(value string) (Rate, error) {
+	limit, period, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("expected limit/period")
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n <= 0 {
+		return Rate{}, fmt.Errorf("limit must be a positive integer")
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("period must be a positive duration")
+	}
+	return Rate{Limit: n, Period: d}, nil
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// RateLimiter - token bucket на пользователя и поле мутации.
+// Лимиты по умолчанию задаются в схеме директивой @rateLimit, overrides их переопределяют.
+type RateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	overrides map[string]Rate
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewRateLimiter(overrides map[string]Rate) *RateLimiter {
+	return &RateLimiter{
+		buckets:   make(map[string]*bucket),
+		overrides: overrides,
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Allow забирает токен из корзины и возвращает, сколько ждать, если токенов нет
+func (l *RateLimiter) Allow(userID int, field string, rate Rate) time.Duration {
+	if override, ok := l.overrides[field]; ok {
+		rate = override
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	perSecond := float64(rate.Limit) / rate.Period.Seconds()
+	key := fmt.Sprintf("%d:%s", userID, field)
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(rate.Limit), updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(rate.Limit), b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// корзины, к которым давно не обращались, уже полные - их можно выбросить
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) > 24*time.Hour {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Directive - реализация @rateLimit(limit, period)
+func (l *RateLimiter) Directive(ctx context.Context, obj any, next graphql.Resolver, limit int32, period string) (any, error) {
+	userID, ok := auth.GetUserID(ctx)
+	if !ok {
+		return next(ctx)
+	}
+
+	fieldCtx := graphql.GetFieldContext(ctx)
+	rate, err := parseRate(fmt.Sprintf("%d/%s", limit, period))
+	if err != nil {
+		return nil, fmt.Errorf("invalid @rateLimit on %s: %w", fieldCtx.Field.Name, err)
+	}
+
+	if retryAfter := l.Allow(userID, fieldCtx.Field.Name, rate); retryAfter > 0 {
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		return nil, &gqlerror.Error{
+			Message: fmt.Sprintf("rate limit exceeded, retry in %d seconds", seconds),
+			Extensions: map[string]any{
+				"code":       "RATE_LIMITED",
+				"retryAfter": seconds,
+			},
+		}
+	}
+	return next(ctx)
+}
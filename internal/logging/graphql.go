@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// GraphQL - расширение gqlgen: добавляет в логгер операции её имя, тип и пользователя
+// и пишет в лог каждую выполненную операцию с длительностью
+type GraphQL struct {
+	// откуда брать пользователя, чтобы logging не зависел от auth
+	UserID func(ctx context.Context) (int, bool)
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+	graphql.ResponseInterceptor
+} = GraphQL{}
+
+func (g GraphQL) ExtensionName() string {
+	return "Logging"
+}
+
+func (g GraphQL) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (g GraphQL) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	opCtx := graphql.GetOperationContext(ctx)
+
+	logger := FromContext(ctx).With("operation", opCtx.OperationName)
+	if opCtx.Operation != nil {
+		logger = logger.With("operation_type", string(opCtx.Operation.Operation))
+	}
+	if g.UserID != nil {
+		if userID, ok := g.UserID(ctx); ok {
+			logger = logger.With("user_id", userID)
+		}
+	}
+
+	return next(WithLogger(ctx, logger))
+}
+
+func (g GraphQL) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	response := next(ctx)
+
+	opCtx := graphql.GetOperationContext(ctx)
+	// у подписки ответ на каждое событие, их пишем только на debug
+	if opCtx.Operation != nil && opCtx.Operation.Operation == "subscription" {
+		if response != nil {
+			FromContext(ctx).Debug("subscription event", "errors", len(response.Errors))
+		}
+		return response
+	}
+
+	errorCount := 0
+	if response != nil {
+		errorCount = len(response.Errors)
+	}
+	FromContext(ctx).Info("graphql operation",
+		"duration_ms", time.Since(opCtx.Stats.OperationStart).Milliseconds(),
+		"errors", errorCount,
+	)
+	return response
+}
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type ctxKey struct{}
+
+// New создаёт логгер: format "json" для продакшена (сбор логов), иначе текстовый
+func New(w io.Writer, format string, level string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: parseLevel(level)}
+	if strings.ToLower(format) == "json" {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+func parseLevel(level string) slog.Level {
+	var result slog.Level
+	if err := result.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return result
+}
+
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext возвращает логгер запроса (с request_id и т.п.) или логгер по умолчанию
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,79 @@
+package markdown
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+// Renderer превращает текст постов и комментариев в безопасный HTML.
+// Результат кэшируется по хэшу формата и текста, то есть по ревизии:
+// после редактирования появляется новый ключ, а старый HTML вытесняется сам.
+type Renderer struct {
+	markdown goldmark.Markdown
+	policy   *bluemonday.Policy
+	cache    *lru.LRU[string]
+}
+
+func New(cacheSize int) *Renderer {
+	// goldmark по умолчанию - CommonMark без сырого HTML, bluemonday - второй рубеж
+	policy := bluemonday.UGCPolicy()
+	// класс языка у блоков кода (```go), чтобы клиент мог подсветить синтаксис
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+
+	return &Renderer{
+		markdown: goldmark.New(),
+		policy:   policy,
+		cache:    lru.New[string](cacheSize),
+	}
+}
+
+func (r *Renderer) Render(ctx context.Context, format string, content string) (string, error) {
+	sum := sha256.Sum256([]byte(format + "\x00" + content))
+	key := hex.EncodeToString(sum[:])
+	if rendered, ok := r.cache.Get(ctx, key); ok {
+		return rendered, nil
+	}
+
+	var rendered string
+	switch format {
+	case repo_models.ContentFormatMarkdown:
+		var buf bytes.Buffer
+		if err := r.markdown.Convert([]byte(content), &buf); err != nil {
+			return "", err
+		}
+		rendered = r.policy.Sanitize(buf.String())
+	default:
+		rendered = renderPlain(content)
+	}
+
+	r.cache.Add(ctx, key, rendered)
+	return rendered, nil
+}
+
+// renderPlain: пустые строки разделяют абзацы, одиночные переводы строк сохраняются
+func renderPlain(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	var b strings.Builder
+	for _, paragraph := range strings.Split(content, "\n\n") {
+		paragraph = strings.Trim(paragraph, "\n")
+		if strings.TrimSpace(paragraph) == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
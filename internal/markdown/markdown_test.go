@@ -0,0 +1,88 @@
+package markdown
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		content string
+		want    string
+	}{
+		{"plain paragraphs", repo_models.ContentFormatPlain, "one\ntwo\n\n\nthree", "<p>one<br>two</p>\n<p>three</p>\n"},
+		{"plain escapes html", repo_models.ContentFormatPlain, "<b>x</b> & y", "<p>&lt;b&gt;x&lt;/b&gt; &amp; y</p>\n"},
+		{"plain crlf", repo_models.ContentFormatPlain, "a\r\n\r\nb", "<p>a</p>\n<p>b</p>\n"},
+		{"plain empty", repo_models.ContentFormatPlain, "", ""},
+		{"markdown syntax is text in plain", repo_models.ContentFormatPlain, "**bold**", "<p>**bold**</p>\n"},
+		{"markdown", repo_models.ContentFormatMarkdown, "# Title\n\n**bold**", "<h1>Title</h1>\n<p><strong>bold</strong></p>\n"},
+		{"code language class", repo_models.ContentFormatMarkdown, "```go\nfmt.Println()\n```", "<pre><code class=\"language-go\">fmt.Println()\n</code></pre>\n"},
+		{"external link opens in new tab", repo_models.ContentFormatMarkdown, "[x](https://example.com)", "<p><a href=\"https://example.com\" rel=\"nofollow noopener\" target=\"_blank\">x</a></p>\n"},
+		{"relative link", repo_models.ContentFormatMarkdown, "[x](/posts/1)", "<p><a href=\"/posts/1\" rel=\"nofollow\">x</a></p>\n"},
+		{"javascript link dropped", repo_models.ContentFormatMarkdown, "[x](javascript:alert(1))", "<p>x</p>\n"},
+	}
+
+	r := New(100)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Render(context.Background(), tt.format, tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Render(%s, %q) = %q, want %q", tt.format, tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderSanitizes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"script block", "<script>alert(1)</script>"},
+		{"inline script", "hi <script>alert(1)</script>"},
+		{"event handler", "<img src=x onerror=alert(1)>"},
+		{"javascript link", "[x](javascript:alert(1))"},
+		{"html link", `<a href="javascript:alert(1)">x</a>`},
+		{"code class injection", "```go\" onclick=\"alert(1)\nx\n```"},
+	}
+
+	r := New(100)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Render(context.Background(), repo_models.ContentFormatMarkdown, tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, forbidden := range []string{"<script", "onerror", "onclick", "javascript:"} {
+				if strings.Contains(got, forbidden) {
+					t.Errorf("Render(%q) = %q contains %q", tt.content, got, forbidden)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderCacheKeyIncludesFormat(t *testing.T) {
+	r := New(100)
+	ctx := context.Background()
+
+	plain, err := r.Render(ctx, repo_models.ContentFormatPlain, "**bold**")
+	if err != nil {
+		t.Fatal(err)
+	}
+	markdown, err := r.Render(ctx, repo_models.ContentFormatMarkdown, "**bold**")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain == markdown {
+		t.Errorf("plain and markdown renders share a cache entry: %q", plain)
+	}
+}
@@ -0,0 +1,106 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var ErrUnsupportedType = errors.New("unsupported file type, allowed: png, jpeg, gif, webp")
+
+// не декодируем картинки больше 50 мегапикселей: маленький файл может распаковаться в гигабайты
+const maxPixels = 50_000_000
+
+// расширения для ключей в хранилище
+var extensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type Image struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+
+	Thumbnail            []byte
+	ThumbnailContentType string
+	ThumbnailExt         string
+}
+
+// Process определяет тип по содержимому (заголовку Content-Type от клиента не верим),
+// читает размеры и делает превью, вписанное в thumbSize x thumbSize
+func Process(data []byte, thumbSize int) (*Image, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := extensions[contentType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("image is too large: %dx%d", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	thumb, thumbType, thumbExt, err := thumbnail(img, contentType, thumbSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make thumbnail: %w", err)
+	}
+
+	return &Image{
+		ContentType:          contentType,
+		Ext:                  ext,
+		Width:                config.Width,
+		Height:               config.Height,
+		Thumbnail:            thumb,
+		ThumbnailContentType: thumbType,
+		ThumbnailExt:         thumbExt,
+	}, nil
+}
+
+// thumbnail: у jpeg превью тоже jpeg, у остальных png, чтобы не терять прозрачность.
+// Анимированный gif превращается в свой первый кадр.
+func thumbnail(img image.Image, contentType string, size int) ([]byte, string, string, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), "image/jpeg", ".jpg", nil
+	}
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), "image/png", ".png", nil
+}
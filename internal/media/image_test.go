@@ -0,0 +1,141 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		for y := range height {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(width, height)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(width, height), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeGIF(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, testImage(width, height), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withPNGSize переписывает размеры в заголовке IHDR, сами пиксели остаются прежними
+func withPNGSize(data []byte, width, height uint32) []byte {
+	data = bytes.Clone(data)
+	// 8 байт сигнатуры, 4 байта длины, 4 байта типа чанка
+	ihdr := data[16:29]
+	binary.BigEndian.PutUint32(ihdr[0:4], width)
+	binary.BigEndian.PutUint32(ihdr[4:8], height)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		thumbSize     int
+		wantType      string
+		wantExt       string
+		wantWidth     int
+		wantHeight    int
+		wantThumbType string
+		wantThumbW    int
+		wantThumbH    int
+	}{
+		{"wide png", encodePNG(t, 400, 200), 100, "image/png", ".png", 400, 200, "image/png", 100, 50},
+		{"tall jpeg", encodeJPEG(t, 100, 300), 150, "image/jpeg", ".jpg", 100, 300, "image/jpeg", 50, 150},
+		{"gif thumbnail is png", encodeGIF(t, 64, 64), 32, "image/gif", ".gif", 64, 64, "image/png", 32, 32},
+		{"small image is not upscaled", encodePNG(t, 20, 10), 100, "image/png", ".png", 20, 10, "image/png", 20, 10},
+		{"thin image keeps at least a pixel", encodePNG(t, 1000, 2), 100, "image/png", ".png", 1000, 2, "image/png", 100, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Process(tt.data, tt.thumbSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.ContentType != tt.wantType || img.Ext != tt.wantExt {
+				t.Errorf("type = %s %s, want %s %s", img.ContentType, img.Ext, tt.wantType, tt.wantExt)
+			}
+			if img.Width != tt.wantWidth || img.Height != tt.wantHeight {
+				t.Errorf("size = %dx%d, want %dx%d", img.Width, img.Height, tt.wantWidth, tt.wantHeight)
+			}
+			if img.ThumbnailContentType != tt.wantThumbType {
+				t.Errorf("thumbnail type = %s, want %s", img.ThumbnailContentType, tt.wantThumbType)
+			}
+
+			thumb, format, err := image.DecodeConfig(bytes.NewReader(img.Thumbnail))
+			if err != nil {
+				t.Fatalf("thumbnail is not an image: %v", err)
+			}
+			if "image/"+format != tt.wantThumbType {
+				t.Errorf("thumbnail is encoded as %s, want %s", format, tt.wantThumbType)
+			}
+			if thumb.Width != tt.wantThumbW || thumb.Height != tt.wantThumbH {
+				t.Errorf("thumbnail size = %dx%d, want %dx%d", thumb.Width, thumb.Height, tt.wantThumbW, tt.wantThumbH)
+			}
+		})
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	valid := encodePNG(t, 10, 10)
+
+	tests := []struct {
+		name            string
+		data            []byte
+		wantUnsupported bool
+	}{
+		{"text", []byte("definitely not an image"), true},
+		{"html", []byte("<html><body>x</body></html>"), true},
+		{"empty", nil, true},
+		{"truncated png", valid[:40], false},
+		{"too many pixels", withPNGSize(valid, 10000, 10000), false},
+		{"zero width", withPNGSize(valid, 0, 10), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Process(tt.data, 100)
+			if err == nil {
+				t.Fatal("Process succeeded, want error")
+			}
+			if errors.Is(err, ErrUnsupportedType) != tt.wantUnsupported {
+				t.Errorf("error = %v, unsupported type %v", err, tt.wantUnsupported)
+			}
+		})
+	}
+}
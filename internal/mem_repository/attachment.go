@@ -0,0 +1,146 @@
+package mem_repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+type AttachmentRepository struct {
+	mu          sync.RWMutex
+	attachments map[int]*repo_models.Attachment
+	createdAt   map[int]time.Time
+	nextID      int
+}
+
+func NewAttachmentRepository() *AttachmentRepository {
+	return &AttachmentRepository{
+		attachments: make(map[int]*repo_models.Attachment),
+		createdAt:   make(map[int]time.Time),
+		nextID:      1,
+	}
+}
+
+func (r *AttachmentRepository) CreateAttachment(ctx context.Context, attachment *repo_models.Attachment) (*repo_models.Attachment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	created := *attachment
+	created.ID = r.nextID
+	created.PostID = nil
+	r.nextID++
+	r.attachments[created.ID] = &created
+	r.createdAt[created.ID] = time.Now()
+
+	result := created
+	return &result, nil
+}
+
+func (r *AttachmentRepository) SetPostAttachments(ctx context.Context, postID int, userID int, ids []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// сначала проверяем все, чтобы не привязать часть
+	for _, id := range ids {
+		attachment, exists := r.attachments[id]
+		if !exists || attachment.UserID != userID || (attachment.PostID != nil && *attachment.PostID != postID) {
+			return fmt.Errorf("attachment not found")
+		}
+	}
+
+	for _, attachment := range r.attachments {
+		if attachment.PostID != nil && *attachment.PostID == postID && !slices.Contains(ids, attachment.ID) {
+			attachment.PostID = nil
+		}
+	}
+	for _, id := range ids {
+		attachment := r.attachments[id]
+		attachment.PostID = &postID
+	}
+
+	return nil
+}
+
+func (r *AttachmentRepository) GetAttachmentsByPostIDs(ctx context.Context, postIDs []int) (map[int][]*repo_models.Attachment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	attachments := make(map[int][]*repo_models.Attachment)
+	for _, attachment := range r.attachments {
+		if attachment.PostID == nil || !slices.Contains(postIDs, *attachment.PostID) {
+			continue
+		}
+		result := *attachment
+		attachments[*attachment.PostID] = append(attachments[*attachment.PostID], &result)
+	}
+	for _, postAttachments := range attachments {
+		slices.SortFunc(postAttachments, func(a, b *repo_models.Attachment) int {
+			return a.ID - b.ID
+		})
+	}
+
+	return attachments, nil
+}
+
+// DeleteUserData удаляет вложения пользователя
+func (r *AttachmentRepository) DeleteUserData(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, attachment := range r.attachments {
+		if attachment.UserID == userID {
+			delete(r.attachments, id)
+			delete(r.createdAt, id)
+		}
+	}
+
+	return nil
+}
+
+func (r *AttachmentRepository) GetAttachmentsByUserID(ctx context.Context, userID int) ([]*repo_models.Attachment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var attachments []*repo_models.Attachment
+	for _, attachment := range r.attachments {
+		if attachment.UserID == userID {
+			result := *attachment
+			attachments = append(attachments, &result)
+		}
+	}
+
+	return attachments, nil
+}
+
+func (r *AttachmentRepository) DeleteAttachments(ctx context.Context, ids []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		delete(r.attachments, id)
+		delete(r.createdAt, id)
+	}
+
+	return nil
+}
+
+func (r *AttachmentRepository) DeleteStaleAttachments(ctx context.Context, before time.Time) ([]*repo_models.Attachment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted []*repo_models.Attachment
+	for id, attachment := range r.attachments {
+		if attachment.PostID != nil || !r.createdAt[id].Before(before) {
+			continue
+		}
+		deleted = append(deleted, attachment)
+		delete(r.attachments, id)
+		delete(r.createdAt, id)
+	}
+
+	return deleted, nil
+}
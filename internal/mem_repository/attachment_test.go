@@ -0,0 +1,47 @@
+package mem_repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+func TestDeleteStaleAttachments(t *testing.T) {
+	ctx := context.Background()
+	r := NewAttachmentRepository()
+
+	var ids []int
+	for range 3 {
+		attachment, err := r.CreateAttachment(ctx, &repo_models.Attachment{UserID: 1, Key: "a.png", ThumbnailKey: "a_thumb.png"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, attachment.ID)
+	}
+	// первое привязано к посту, второе загружено давно, третье только что
+	if err := r.SetPostAttachments(ctx, 10, 1, ids[:1]); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, id := range ids[:2] {
+		r.createdAt[id] = now.Add(-2 * time.Hour)
+	}
+
+	deleted, err := r.DeleteStaleAttachments(ctx, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != ids[1] {
+		t.Fatalf("deleted = %v, want only attachment %d", deleted, ids[1])
+	}
+
+	left, err := r.GetAttachmentsByUserID(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 {
+		t.Errorf("attachments left = %d, want 2", len(left))
+	}
+}
@@ -0,0 +1,133 @@
+package mem_repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+type bookmarkKey struct {
+	userID int
+	postID int
+}
+
+// BookmarkRepository, как и TagRepository, смотрит в PostRepository,
+// чтобы не отдавать закладки на удалённые посты
+type BookmarkRepository struct {
+	mu        sync.RWMutex
+	posts     *PostRepository
+	bookmarks map[bookmarkKey]int
+	nextID    int
+}
+
+func NewBookmarkRepository(posts *PostRepository) *BookmarkRepository {
+	return &BookmarkRepository{
+		posts:     posts,
+		bookmarks: make(map[bookmarkKey]int),
+		nextID:    1,
+	}
+}
+
+func (r *BookmarkRepository) BookmarkPost(ctx context.Context, userID int, postID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := bookmarkKey{userID: userID, postID: postID}
+	if _, exists := r.bookmarks[key]; !exists {
+		r.bookmarks[key] = r.nextID
+		r.nextID++
+	}
+	return nil
+}
+
+func (r *BookmarkRepository) UnbookmarkPost(ctx context.Context, userID int, postID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.bookmarks, bookmarkKey{userID: userID, postID: postID})
+	return nil
+}
+
+func (r *BookmarkRepository) GetBookmarks(ctx context.Context, userID int, limit int, afterID int) ([]*repo_models.Bookmark, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var bookmarks []*repo_models.Bookmark
+	for key, id := range r.bookmarks {
+		if key.userID != userID || (afterID != 0 && id >= afterID) {
+			continue
+		}
+		bookmarks = append(bookmarks, &repo_models.Bookmark{ID: id, UserID: userID, Post: &repo_models.Post{ID: key.postID}})
+	}
+	sort.Slice(bookmarks, func(i, j int) bool {
+		return bookmarks[i].ID > bookmarks[j].ID
+	})
+
+	var result []*repo_models.Bookmark
+	for _, bookmark := range bookmarks {
+		if len(result) == limit {
+			break
+		}
+		post, err := r.posts.GetPostByID(ctx, bookmark.Post.ID)
+		if err != nil {
+			continue
+		}
+		if post.Status != repo_models.PostStatusPublished && post.Status != repo_models.PostStatusArchived && post.UserID != userID {
+			continue
+		}
+		bookmark.Post = post
+		result = append(result, bookmark)
+	}
+
+	return result, nil
+}
+
+func (r *BookmarkRepository) CountBookmarksByPostIDs(ctx context.Context, postIDs []int) (map[int]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[int]bool)
+	for _, postID := range postIDs {
+		wanted[postID] = true
+	}
+
+	counts := make(map[int]int)
+	for key := range r.bookmarks {
+		if wanted[key.postID] {
+			counts[key.postID]++
+		}
+	}
+
+	return counts, nil
+}
+
+func (r *BookmarkRepository) GetBookmarkedPostIDs(ctx context.Context, userID int, postIDs []int) (map[int]bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	bookmarked := make(map[int]bool)
+	for _, postID := range postIDs {
+		if _, exists := r.bookmarks[bookmarkKey{userID: userID, postID: postID}]; exists {
+			bookmarked[postID] = true
+		}
+	}
+
+	return bookmarked, nil
+}
+
+// DeleteUserData удаляет закладки пользователя. Закладки на удалённые посты
+// не отдаются и так, см. комментарий к BookmarkRepository.
+func (r *BookmarkRepository) DeleteUserData(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key := range r.bookmarks {
+		if key.userID == userID {
+			delete(r.bookmarks, key)
+		}
+	}
+
+	return nil
+}
@@ -4,48 +4,77 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/AntonCkya/ozon_habr/internal/repo_models"
 )
 
+// CommentRepository ведёт счётчики комментариев в PostRepository, как триггер в postgres
 type CommentRepository struct {
-	mu       sync.RWMutex
-	comments map[int]*repo_models.Comment
-	nextID   int
+	mu        sync.RWMutex
+	posts     *PostRepository
+	events    *CommentEventRepository
+	comments  map[int]*repo_models.Comment
+	createdAt map[int]time.Time
+	nextID    int
 }
 
-func NewCommentRepository() *CommentRepository {
+func NewCommentRepository(posts *PostRepository, events *CommentEventRepository) *CommentRepository {
 	return &CommentRepository{
-		comments: make(map[int]*repo_models.Comment),
-		nextID:   1,
+		posts:     posts,
+		events:    events,
+		comments:  make(map[int]*repo_models.Comment),
+		createdAt: make(map[int]time.Time),
+		nextID:    1,
 	}
 }
 
-func (r *CommentRepository) CreateComment(ctx context.Context, content string, userID, postID, parentID int) (*repo_models.Comment, error) {
+// latestCommentAt - время последнего комментария поста, вызывается под r.mu
+func (r *CommentRepository) latestCommentAt(postID int) *time.Time {
+	var latest *time.Time
+	for id, comment := range r.comments {
+		if comment.PostID != postID {
+			continue
+		}
+		if createdAt := r.createdAt[id]; latest == nil || createdAt.After(*latest) {
+			latest = &createdAt
+		}
+	}
+	return latest
+}
+
+func (r *CommentRepository) CreateComment(ctx context.Context, content, contentFormat string, userID, postID, parentID int) (*repo_models.Comment, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	comment := &repo_models.Comment{
-		ID:       r.nextID,
-		Content:  content,
-		UserID:   userID,
-		PostID:   postID,
-		ParentID: nil,
+		ID:            r.nextID,
+		Content:       content,
+		ContentFormat: contentFormat,
+		UserID:        userID,
+		PostID:        postID,
+		ParentID:      nil,
 	}
 
 	if parentID != -1 {
 		comment.ParentID = &parentID
 	}
 
+	createdAt := time.Now()
 	r.comments[comment.ID] = comment
+	r.createdAt[comment.ID] = createdAt
 	r.nextID++
+	r.posts.commentAdded(postID, createdAt)
+	event := r.events.add(comment)
 
 	return &repo_models.Comment{
-		ID:       comment.ID,
-		Content:  comment.Content,
-		UserID:   comment.UserID,
-		PostID:   comment.PostID,
-		ParentID: comment.ParentID,
+		ID:            comment.ID,
+		Content:       comment.Content,
+		ContentFormat: comment.ContentFormat,
+		UserID:        comment.UserID,
+		PostID:        comment.PostID,
+		ParentID:      comment.ParentID,
+		EventID:       event.ID,
 	}, nil
 }
 
@@ -72,11 +101,12 @@ func (r *CommentRepository) GetCommentsByPostID(ctx context.Context, postID int,
 	result := make([]*repo_models.Comment, 0, len(comments))
 	for _, comment := range comments {
 		result = append(result, &repo_models.Comment{
-			ID:       comment.ID,
-			Content:  comment.Content,
-			UserID:   comment.UserID,
-			PostID:   comment.PostID,
-			ParentID: comment.ParentID,
+			ID:            comment.ID,
+			Content:       comment.Content,
+			ContentFormat: comment.ContentFormat,
+			UserID:        comment.UserID,
+			PostID:        comment.PostID,
+			ParentID:      comment.ParentID,
 		})
 	}
 
@@ -95,11 +125,12 @@ func (r *CommentRepository) GetCommentsByPostIDs(ctx context.Context, postIDs []
 	for _, comment := range r.comments {
 		if _, exists := postIDSet[comment.PostID]; exists {
 			result = append(result, &repo_models.Comment{
-				ID:       comment.ID,
-				Content:  comment.Content,
-				UserID:   comment.UserID,
-				PostID:   comment.PostID,
-				ParentID: comment.ParentID,
+				ID:            comment.ID,
+				Content:       comment.Content,
+				ContentFormat: comment.ContentFormat,
+				UserID:        comment.UserID,
+				PostID:        comment.PostID,
+				ParentID:      comment.ParentID,
 			})
 		}
 	}
@@ -115,11 +146,12 @@ func (r *CommentRepository) GetReplies(ctx context.Context, parentID int) ([]*re
 	for _, comment := range r.comments {
 		if comment.ParentID != nil && *comment.ParentID == parentID {
 			replies = append(replies, &repo_models.Comment{
-				ID:       comment.ID,
-				Content:  comment.Content,
-				UserID:   comment.UserID,
-				PostID:   comment.PostID,
-				ParentID: comment.ParentID,
+				ID:            comment.ID,
+				Content:       comment.Content,
+				ContentFormat: comment.ContentFormat,
+				UserID:        comment.UserID,
+				PostID:        comment.PostID,
+				ParentID:      comment.ParentID,
 			})
 		}
 	}
@@ -137,15 +169,16 @@ func (r *CommentRepository) GetCommentByID(ctx context.Context, id int) (*repo_m
 	}
 
 	return &repo_models.Comment{
-		ID:       comment.ID,
-		Content:  comment.Content,
-		UserID:   comment.UserID,
-		PostID:   comment.PostID,
-		ParentID: comment.ParentID,
+		ID:            comment.ID,
+		Content:       comment.Content,
+		ContentFormat: comment.ContentFormat,
+		UserID:        comment.UserID,
+		PostID:        comment.PostID,
+		ParentID:      comment.ParentID,
 	}, nil
 }
 
-func (r *CommentRepository) UpdateComment(ctx context.Context, id int, content string) (*repo_models.Comment, error) {
+func (r *CommentRepository) UpdateComment(ctx context.Context, id int, content, contentFormat string) (*repo_models.Comment, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -155,13 +188,15 @@ func (r *CommentRepository) UpdateComment(ctx context.Context, id int, content s
 	}
 
 	comment.Content = content
+	comment.ContentFormat = contentFormat
 
 	return &repo_models.Comment{
-		ID:       comment.ID,
-		Content:  comment.Content,
-		UserID:   comment.UserID,
-		PostID:   comment.PostID,
-		ParentID: comment.ParentID,
+		ID:            comment.ID,
+		Content:       comment.Content,
+		ContentFormat: comment.ContentFormat,
+		UserID:        comment.UserID,
+		PostID:        comment.PostID,
+		ParentID:      comment.ParentID,
 	}, nil
 }
 
@@ -169,11 +204,107 @@ func (r *CommentRepository) DeleteComment(ctx context.Context, id int) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	_, exists := r.comments[id]
+	comment, exists := r.comments[id]
 	if !exists {
 		return errors.New("comment not found")
 	}
 
+	createdAt := r.createdAt[id]
 	delete(r.comments, id)
+	delete(r.createdAt, id)
+	r.posts.commentsDeleted(comment.PostID, 1, createdAt, func() *time.Time {
+		return r.latestCommentAt(comment.PostID)
+	})
 	return nil
 }
+
+func (r *CommentRepository) CountCommentsByUserIDs(ctx context.Context, userIDs []int) (map[int]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[int]bool, len(userIDs))
+	for _, id := range userIDs {
+		wanted[id] = true
+	}
+
+	counts := make(map[int]int)
+	for _, comment := range r.comments {
+		if wanted[comment.UserID] {
+			counts[comment.UserID]++
+		}
+	}
+
+	return counts, nil
+}
+
+// DeleteUserData удаляет комментарии пользователя и комментарии к его постам
+// вместе со всеми ответами на них и их событиями, как каскад в postgres.
+// Вызывается до удаления постов: их id берутся из PostRepository.
+func (r *CommentRepository) DeleteUserData(ctx context.Context, userID int) error {
+	postIDs := r.posts.postIDsByUser(userID)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	postIDSet := make(map[int]struct{})
+	for _, id := range postIDs {
+		postIDSet[id] = struct{}{}
+	}
+
+	deleted := make(map[int]struct{})
+	for id, comment := range r.comments {
+		if _, exists := postIDSet[comment.PostID]; exists || comment.UserID == userID {
+			deleted[id] = struct{}{}
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for id, comment := range r.comments {
+			if _, exists := deleted[id]; exists || comment.ParentID == nil {
+				continue
+			}
+			if _, exists := deleted[*comment.ParentID]; exists {
+				deleted[id] = struct{}{}
+				changed = true
+			}
+		}
+	}
+
+	type postStats struct {
+		count  int
+		newest time.Time
+	}
+	affectedPosts := make(map[int]*postStats)
+	for id := range deleted {
+		postID := r.comments[id].PostID
+		stats, exists := affectedPosts[postID]
+		if !exists {
+			stats = &postStats{}
+			affectedPosts[postID] = stats
+		}
+		stats.count++
+		if createdAt := r.createdAt[id]; createdAt.After(stats.newest) {
+			stats.newest = createdAt
+		}
+		delete(r.comments, id)
+		delete(r.createdAt, id)
+	}
+	for postID, stats := range affectedPosts {
+		r.posts.commentsDeleted(postID, stats.count, stats.newest, func() *time.Time {
+			return r.latestCommentAt(postID)
+		})
+	}
+	r.events.deleteComments(deleted)
+
+	return nil
+}
+
+// exists нужен NotificationRepository, чтобы убирать уведомления об удалённых комментариях
+func (r *CommentRepository) exists(id int) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, exists := r.comments[id]
+	return exists
+}
@@ -0,0 +1,123 @@
+package mem_repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+// кольцевой буфер последних событий одного поста
+type commentEventRing struct {
+	events []*repo_models.CommentEvent
+	start  int
+	count  int
+}
+
+func (r *commentEventRing) push(event *repo_models.CommentEvent) {
+	if r.count < len(r.events) {
+		r.events[(r.start+r.count)%len(r.events)] = event
+		r.count++
+		return
+	}
+	r.events[r.start] = event
+	r.start = (r.start + 1) % len(r.events)
+}
+
+type CommentEventRepository struct {
+	mu     sync.RWMutex
+	logs   map[int]*commentEventRing
+	size   int
+	nextID int
+}
+
+func NewCommentEventRepository(size int) *CommentEventRepository {
+	return &CommentEventRepository{
+		logs:   make(map[int]*commentEventRing),
+		size:   size,
+		nextID: 1,
+	}
+}
+
+// add записывает событие о новом комментарии. Вызывается из CommentRepository.CreateComment
+// под его блокировкой, поэтому комментарий и событие появляются вместе.
+func (r *CommentEventRepository) add(comment *repo_models.Comment) *repo_models.CommentEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log, exists := r.logs[comment.PostID]
+	if !exists {
+		log = &commentEventRing{events: make([]*repo_models.CommentEvent, r.size)}
+		r.logs[comment.PostID] = log
+	}
+
+	event := &repo_models.CommentEvent{
+		ID:     r.nextID,
+		PostID: comment.PostID,
+		Comment: &repo_models.Comment{
+			ID:            comment.ID,
+			Content:       comment.Content,
+			ContentFormat: comment.ContentFormat,
+			UserID:        comment.UserID,
+			PostID:        comment.PostID,
+			ParentID:      comment.ParentID,
+		},
+	}
+	log.push(event)
+	r.nextID++
+
+	return event
+}
+
+func (r *CommentEventRepository) GetCommentEventsSince(ctx context.Context, postID int, sinceID int) ([]*repo_models.CommentEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	log, exists := r.logs[postID]
+	if !exists {
+		return nil, nil
+	}
+
+	var result []*repo_models.CommentEvent
+	for i := 0; i < log.count; i++ {
+		event := log.events[(log.start+i)%len(log.events)]
+		if event.ID <= sinceID {
+			continue
+		}
+		result = append(result, &repo_models.CommentEvent{
+			ID:     event.ID,
+			PostID: event.PostID,
+			Comment: &repo_models.Comment{
+				ID:            event.Comment.ID,
+				Content:       event.Comment.Content,
+				ContentFormat: event.Comment.ContentFormat,
+				UserID:        event.Comment.UserID,
+				PostID:        event.Comment.PostID,
+				ParentID:      event.Comment.ParentID,
+			},
+		})
+	}
+
+	return result, nil
+}
+
+// deleteComments убирает из журналов события удалённых комментариев
+func (r *CommentEventRepository) deleteComments(ids map[int]struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for postID, log := range r.logs {
+		kept := &commentEventRing{events: make([]*repo_models.CommentEvent, len(log.events))}
+		for i := 0; i < log.count; i++ {
+			event := log.events[(log.start+i)%len(log.events)]
+			if _, deleted := ids[event.Comment.ID]; !deleted {
+				kept.push(event)
+			}
+		}
+		if kept.count == 0 {
+			delete(r.logs, postID)
+			continue
+		}
+		r.logs[postID] = kept
+	}
+}
@@ -0,0 +1,53 @@
+package mem_repository
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCommentEventsReplay(t *testing.T) {
+	ctx := context.Background()
+	posts := NewPostRepository()
+	events := NewCommentEventRepository(3)
+	comments := NewCommentRepository(posts, events)
+
+	var eventIDs []int
+	for _, postID := range []int{1, 2, 1, 1, 1} {
+		comment, err := comments.CreateComment(ctx, "text", "PLAIN", 1, postID, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eventIDs = append(eventIDs, comment.EventID)
+	}
+
+	tests := []struct {
+		name    string
+		postID  int
+		sinceID int
+		want    []int
+	}{
+		// в журнале поста 1 остались только 3 последних события
+		{"trimmed log", 1, 0, []int{eventIDs[2], eventIDs[3], eventIDs[4]}},
+		{"since middle", 1, eventIDs[3], []int{eventIDs[4]}},
+		{"since last", 1, eventIDs[4], nil},
+		{"other post", 2, 0, []int{eventIDs[1]}},
+		{"unknown post", 3, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := events.GetCommentEventsSince(ctx, tt.postID, tt.sinceID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d events, want %d", len(got), len(tt.want))
+			}
+			for i, event := range got {
+				if event.ID != tt.want[i] || event.Comment.PostID != tt.postID {
+					t.Errorf("event %d = {id %d, post %d}, want {id %d, post %d}", i, event.ID, event.Comment.PostID, tt.want[i], tt.postID)
+				}
+			}
+		})
+	}
+}
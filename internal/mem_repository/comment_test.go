@@ -0,0 +1,71 @@
+package mem_repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+func TestCommentStats(t *testing.T) {
+	ctx := context.Background()
+	posts := NewPostRepository()
+	comments := NewCommentRepository(posts, NewCommentEventRepository(10))
+
+	post, err := posts.CreatePost(ctx, "title", "text", repo_models.ContentFormatPlain, 1, true, repo_models.PostStatusPublished, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	for range 3 {
+		comment, err := comments.CreateComment(ctx, "text", repo_models.ContentFormatPlain, 2, post.ID, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, comment.ID)
+	}
+	createdAt := func(i int) *time.Time {
+		at := comments.createdAt[ids[i]]
+		return &at
+	}
+	first, third := createdAt(0), createdAt(2)
+
+	tests := []struct {
+		name      string
+		deleteID  int
+		wantCount int
+		wantLast  *time.Time
+	}{
+		{"delete older comment", ids[1], 2, third},
+		{"delete latest comment", ids[2], 1, first},
+		{"delete last comment", ids[0], 0, nil},
+	}
+
+	got, _ := posts.GetPostByID(ctx, post.ID)
+	if got.CommentCount != 3 || !got.LastCommentAt.Equal(*third) {
+		t.Fatalf("after create: count %d, last %v, want 3, %v", got.CommentCount, got.LastCommentAt, third)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := comments.DeleteComment(ctx, tt.deleteID); err != nil {
+				t.Fatal(err)
+			}
+			got, err := posts.GetPostByID(ctx, post.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.CommentCount != tt.wantCount {
+				t.Errorf("count = %d, want %d", got.CommentCount, tt.wantCount)
+			}
+			switch {
+			case tt.wantLast == nil && got.LastCommentAt != nil:
+				t.Errorf("last comment at = %v, want nil", got.LastCommentAt)
+			case tt.wantLast != nil && (got.LastCommentAt == nil || !got.LastCommentAt.Equal(*tt.wantLast)):
+				t.Errorf("last comment at = %v, want %v", got.LastCommentAt, tt.wantLast)
+			}
+		})
+	}
+}
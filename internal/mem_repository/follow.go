@@ -0,0 +1,147 @@
+package mem_repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+type followKey struct {
+	followerID int
+	userID     int
+}
+
+// FollowRepository собирает ленту из PostRepository и TagRepository
+type FollowRepository struct {
+	mu      sync.RWMutex
+	posts   *PostRepository
+	tags    *TagRepository
+	follows map[followKey]int
+	nextID  int
+}
+
+func NewFollowRepository(posts *PostRepository, tags *TagRepository) *FollowRepository {
+	return &FollowRepository{
+		posts:   posts,
+		tags:    tags,
+		follows: make(map[followKey]int),
+		nextID:  1,
+	}
+}
+
+func (r *FollowRepository) FollowUser(ctx context.Context, followerID int, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := followKey{followerID: followerID, userID: userID}
+	if _, exists := r.follows[key]; !exists {
+		r.follows[key] = r.nextID
+		r.nextID++
+	}
+	return nil
+}
+
+func (r *FollowRepository) UnfollowUser(ctx context.Context, followerID int, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.follows, followKey{followerID: followerID, userID: userID})
+	return nil
+}
+
+func (r *FollowRepository) GetFollowers(ctx context.Context, userID int, limit int, afterID int) ([]*repo_models.Follow, error) {
+	return r.list(limit, afterID, func(key followKey) bool { return key.userID == userID }), nil
+}
+
+func (r *FollowRepository) GetFollowing(ctx context.Context, userID int, limit int, afterID int) ([]*repo_models.Follow, error) {
+	return r.list(limit, afterID, func(key followKey) bool { return key.followerID == userID }), nil
+}
+
+func (r *FollowRepository) CountFollowers(ctx context.Context, userID int) (int, error) {
+	return len(r.list(-1, 0, func(key followKey) bool { return key.userID == userID })), nil
+}
+
+func (r *FollowRepository) CountFollowing(ctx context.Context, userID int) (int, error) {
+	return len(r.list(-1, 0, func(key followKey) bool { return key.followerID == userID })), nil
+}
+
+// list - подписки, подходящие под match, новые первыми; limit -1 - без ограничения
+func (r *FollowRepository) list(limit int, afterID int, match func(followKey) bool) []*repo_models.Follow {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var follows []*repo_models.Follow
+	for key, id := range r.follows {
+		if !match(key) || (afterID != 0 && id >= afterID) {
+			continue
+		}
+		follows = append(follows, &repo_models.Follow{ID: id, FollowerID: key.followerID, UserID: key.userID})
+	}
+	sort.Slice(follows, func(i, j int) bool {
+		return follows[i].ID > follows[j].ID
+	})
+	if limit >= 0 && len(follows) > limit {
+		follows = follows[:limit]
+	}
+
+	return follows
+}
+
+func (r *FollowRepository) GetFeed(ctx context.Context, userID int, limit int, after *repo_models.FeedCursor) ([]*repo_models.Post, error) {
+	authors := make(map[int]bool)
+	for _, follow := range r.list(-1, 0, func(key followKey) bool { return key.followerID == userID }) {
+		authors[follow.UserID] = true
+	}
+
+	followedTags, err := r.tags.GetFollowedTags(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := r.posts.publishedPostsBefore(after)
+	postIDs := make([]int, 0, len(candidates))
+	for _, post := range candidates {
+		postIDs = append(postIDs, post.ID)
+	}
+	postTags, err := r.tags.GetTagsByPostIDs(ctx, postIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var feed []*repo_models.Post
+	for _, post := range candidates {
+		if len(feed) == limit {
+			break
+		}
+		if authors[post.UserID] || hasAnyTag(postTags[post.ID], followedTags) {
+			feed = append(feed, post)
+		}
+	}
+
+	return feed, nil
+}
+
+func hasAnyTag(tags []string, wanted []string) bool {
+	for _, tag := range tags {
+		if containsTag(wanted, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteUserData удаляет подписки пользователя и подписки на него
+func (r *FollowRepository) DeleteUserData(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key := range r.follows {
+		if key.followerID == userID || key.userID == userID {
+			delete(r.follows, key)
+		}
+	}
+
+	return nil
+}
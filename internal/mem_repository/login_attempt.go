@@ -0,0 +1,84 @@
+package mem_repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+type LoginAttemptRepository struct {
+	mu       sync.RWMutex
+	attempts map[string]*repo_models.LoginAttempts
+}
+
+func NewLoginAttemptRepository() *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		attempts: make(map[string]*repo_models.LoginAttempts),
+	}
+}
+
+func (r *LoginAttemptRepository) GetLoginAttempts(ctx context.Context, key string) (*repo_models.LoginAttempts, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	attempts, exists := r.attempts[key]
+	if !exists {
+		return nil, nil
+	}
+
+	result := *attempts
+	return &result, nil
+}
+
+func (r *LoginAttemptRepository) AddLoginFailure(ctx context.Context, key string, now time.Time, windowStart time.Time) (*repo_models.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts, exists := r.attempts[key]
+	if !exists {
+		attempts = &repo_models.LoginAttempts{Key: key, LockedUntil: now}
+		r.attempts[key] = attempts
+	}
+	if attempts.LastFailureAt.Before(windowStart) {
+		attempts.Failures = 0
+	}
+	attempts.Failures++
+	attempts.LastFailureAt = now
+
+	result := *attempts
+	return &result, nil
+}
+
+func (r *LoginAttemptRepository) LockLoginAttempts(ctx context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempts, exists := r.attempts[key]; exists && until.After(attempts.LockedUntil) {
+		attempts.LockedUntil = until
+	}
+	return nil
+}
+
+func (r *LoginAttemptRepository) ResetLoginAttempts(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
+
+func (r *LoginAttemptRepository) DeleteStaleLoginAttempts(ctx context.Context, before time.Time, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for key, attempts := range r.attempts {
+		if attempts.LastFailureAt.Before(before) && attempts.LockedUntil.Before(now) {
+			delete(r.attempts, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,125 @@
+package mem_repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+// NotificationRepository смотрит в CommentRepository, чтобы при удалении аккаунта
+// убрать уведомления об удалённых комментариях, как ON DELETE CASCADE в pg
+type NotificationRepository struct {
+	mu            sync.RWMutex
+	comments      *CommentRepository
+	notifications map[int]*repo_models.Notification
+	nextID        int
+}
+
+func NewNotificationRepository(comments *CommentRepository) *NotificationRepository {
+	return &NotificationRepository{
+		comments:      comments,
+		notifications: make(map[int]*repo_models.Notification),
+		nextID:        1,
+	}
+}
+
+func (r *NotificationRepository) CreateNotification(ctx context.Context, userID, actorID int, notificationType string, postID, commentID int) (*repo_models.Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	notification := &repo_models.Notification{
+		ID:        r.nextID,
+		UserID:    userID,
+		ActorID:   actorID,
+		Type:      notificationType,
+		PostID:    postID,
+		CommentID: commentID,
+		Read:      false,
+		CreatedAt: time.Now(),
+	}
+
+	r.notifications[notification.ID] = notification
+	r.nextID++
+
+	result := *notification
+	return &result, nil
+}
+
+func (r *NotificationRepository) GetNotifications(ctx context.Context, userID int, limit int, afterID int, unreadOnly bool) ([]*repo_models.Notification, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var userNotifications []*repo_models.Notification
+	for _, notification := range r.notifications {
+		if notification.UserID != userID {
+			continue
+		}
+		if afterID != 0 && notification.ID >= afterID {
+			continue
+		}
+		if unreadOnly && notification.Read {
+			continue
+		}
+		userNotifications = append(userNotifications, notification)
+	}
+
+	sort.Slice(userNotifications, func(i, j int) bool {
+		return userNotifications[i].ID > userNotifications[j].ID
+	})
+	if len(userNotifications) > limit {
+		userNotifications = userNotifications[:limit]
+	}
+
+	result := make([]*repo_models.Notification, 0, len(userNotifications))
+	for _, notification := range userNotifications {
+		copied := *notification
+		result = append(result, &copied)
+	}
+
+	return result, nil
+}
+
+func (r *NotificationRepository) MarkNotificationsRead(ctx context.Context, userID int, ids []int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	marked := 0
+	if ids == nil {
+		for _, notification := range r.notifications {
+			if notification.UserID == userID && !notification.Read {
+				notification.Read = true
+				marked++
+			}
+		}
+		return marked, nil
+	}
+
+	for _, id := range ids {
+		notification, exists := r.notifications[id]
+		if !exists || notification.UserID != userID || notification.Read {
+			continue
+		}
+		notification.Read = true
+		marked++
+	}
+
+	return marked, nil
+}
+
+// DeleteUserData удаляет уведомления пользователя, уведомления о его действиях
+// и об удалённых вместе с ним комментариях. Вызывается после CommentRepository.DeleteUserData.
+func (r *NotificationRepository) DeleteUserData(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, notification := range r.notifications {
+		if notification.UserID == userID || notification.ActorID == userID || !r.comments.exists(notification.CommentID) {
+			delete(r.notifications, id)
+		}
+	}
+
+	return nil
+}
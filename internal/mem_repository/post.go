@@ -3,7 +3,9 @@ package mem_repository
 import (
 	"context"
 	"errors"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/AntonCkya/ozon_habr/internal/repo_models"
 )
@@ -21,27 +23,35 @@ func NewPostRepository() *PostRepository {
 	}
 }
 
-func (r *PostRepository) CreatePost(ctx context.Context, title, content string, userID int, commentable bool) (*repo_models.Post, error) {
+func (r *PostRepository) CreatePost(ctx context.Context, title, content, contentFormat string, userID int, commentable bool, status string, publishAt *time.Time) (*repo_models.Post, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	post := &repo_models.Post{
-		ID:          r.nextID,
-		Title:       title,
-		Content:     content,
-		UserID:      userID,
-		Commentable: commentable,
+		ID:            r.nextID,
+		Title:         title,
+		Content:       content,
+		ContentFormat: contentFormat,
+		UserID:        userID,
+		Commentable:   commentable,
+		Status:        status,
+		PublishAt:     publishAt,
 	}
 
 	r.posts[post.ID] = post
 	r.nextID++
 
 	return &repo_models.Post{
-		ID:          post.ID,
-		Title:       post.Title,
-		Content:     post.Content,
-		UserID:      post.UserID,
-		Commentable: post.Commentable,
+		ID:            post.ID,
+		Title:         post.Title,
+		Content:       post.Content,
+		ContentFormat: post.ContentFormat,
+		UserID:        post.UserID,
+		Commentable:   post.Commentable,
+		Status:        post.Status,
+		PublishAt:     post.PublishAt,
+		CommentCount:  post.CommentCount,
+		LastCommentAt: post.LastCommentAt,
 	}, nil
 }
 
@@ -55,21 +65,51 @@ func (r *PostRepository) GetPostByID(ctx context.Context, id int) (*repo_models.
 	}
 
 	return &repo_models.Post{
-		ID:          post.ID,
-		Title:       post.Title,
-		Content:     post.Content,
-		UserID:      post.UserID,
-		Commentable: post.Commentable,
+		ID:            post.ID,
+		Title:         post.Title,
+		Content:       post.Content,
+		ContentFormat: post.ContentFormat,
+		UserID:        post.UserID,
+		Commentable:   post.Commentable,
+		Status:        post.Status,
+		PublishAt:     post.PublishAt,
+		CommentCount:  post.CommentCount,
+		LastCommentAt: post.LastCommentAt,
 	}, nil
 }
 
-func (r *PostRepository) GetPosts(ctx context.Context, limit int, offset int) ([]*repo_models.Post, error) {
+func (r *PostRepository) GetPosts(ctx context.Context, limit int, offset int, order string) ([]*repo_models.Post, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	allPosts := make([]*repo_models.Post, 0, len(r.posts))
 	for _, post := range r.posts {
-		allPosts = append(allPosts, post)
+		if post.Status == repo_models.PostStatusPublished {
+			allPosts = append(allPosts, post)
+		}
+	}
+	switch order {
+	case repo_models.PostOrderMostDiscussed:
+		sort.Slice(allPosts, func(i, j int) bool {
+			if allPosts[i].CommentCount != allPosts[j].CommentCount {
+				return allPosts[i].CommentCount > allPosts[j].CommentCount
+			}
+			return allPosts[i].ID > allPosts[j].ID
+		})
+	case repo_models.PostOrderRecentlyDiscussed:
+		// посты без комментариев в конце, как NULLS LAST в postgres
+		sort.Slice(allPosts, func(i, j int) bool {
+			a, b := allPosts[i].LastCommentAt, allPosts[j].LastCommentAt
+			if a != nil && b != nil && !a.Equal(*b) {
+				return a.After(*b)
+			}
+			if (a == nil) != (b == nil) {
+				return a != nil
+			}
+			return allPosts[i].ID > allPosts[j].ID
+		})
+	default:
+		sortByPublishTime(allPosts)
 	}
 	start := offset
 	if start > len(allPosts) {
@@ -84,27 +124,134 @@ func (r *PostRepository) GetPosts(ctx context.Context, limit int, offset int) ([
 	result := make([]*repo_models.Post, 0, len(posts))
 	for _, post := range posts {
 		result = append(result, &repo_models.Post{
-			ID:          post.ID,
-			Title:       post.Title,
-			Content:     post.Content,
-			UserID:      post.UserID,
-			Commentable: post.Commentable,
+			ID:            post.ID,
+			Title:         post.Title,
+			Content:       post.Content,
+			ContentFormat: post.ContentFormat,
+			UserID:        post.UserID,
+			Commentable:   post.Commentable,
+			Status:        post.Status,
+			PublishAt:     post.PublishAt,
+			CommentCount:  post.CommentCount,
+			LastCommentAt: post.LastCommentAt,
 		})
 	}
 
 	return result, nil
 }
 
+// commentAdded и commentsDeleted вызывает CommentRepository под своей блокировкой,
+// поэтому счётчики меняются вместе с комментариями
+func (r *PostRepository) commentAdded(postID int, createdAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	post, exists := r.posts[postID]
+	if !exists {
+		return
+	}
+	post.CommentCount++
+	if post.LastCommentAt == nil || createdAt.After(*post.LastCommentAt) {
+		post.LastCommentAt = &createdAt
+	}
+}
+
+// commentsDeleted уменьшает счётчик на count. Время последнего комментария пересчитывает latest,
+// только если удалён сам последний комментарий (newest - самый поздний из удалённых).
+func (r *PostRepository) commentsDeleted(postID int, count int, newest time.Time, latest func() *time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	post, exists := r.posts[postID]
+	if !exists {
+		return
+	}
+	post.CommentCount -= count
+	if post.LastCommentAt != nil && !newest.Before(*post.LastCommentAt) {
+		post.LastCommentAt = latest()
+	}
+}
+
+// postIDsByUser - id всех постов пользователя, включая черновики
+func (r *PostRepository) postIDsByUser(userID int) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ids []int
+	for id, post := range r.posts {
+		if post.UserID == userID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// publishedPostsBefore - опубликованные посты после курсора ленты (nil - все),
+// в порядке ленты: по времени публикации, затем по id, новые первыми
+func (r *PostRepository) publishedPostsBefore(cursor *repo_models.FeedCursor) []*repo_models.Post {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []*repo_models.Post
+	for _, post := range r.posts {
+		if post.Status != repo_models.PostStatusPublished {
+			continue
+		}
+		if cursor != nil && !feedLess(post, cursor.PublishAt, cursor.ID) {
+			continue
+		}
+		result = append(result, &repo_models.Post{
+			ID:            post.ID,
+			Title:         post.Title,
+			Content:       post.Content,
+			ContentFormat: post.ContentFormat,
+			UserID:        post.UserID,
+			Commentable:   post.Commentable,
+			Status:        post.Status,
+			PublishAt:     post.PublishAt,
+			CommentCount:  post.CommentCount,
+			LastCommentAt: post.LastCommentAt,
+		})
+	}
+	sortByPublishTime(result)
+
+	return result
+}
+
+// feedLess - идёт ли пост в ленте после позиции (publishAt, id), как (publish_at, id) < ($2, $3) в pg
+func feedLess(post *repo_models.Post, publishAt time.Time, id int) bool {
+	postPublishAt := publishTime(post)
+	if !postPublishAt.Equal(publishAt) {
+		return postPublishAt.Before(publishAt)
+	}
+	return post.ID < id
+}
+
+// sortByPublishTime - новые публикации первыми, как ORDER BY publish_at DESC, id DESC в pg
+func sortByPublishTime(posts []*repo_models.Post) {
+	sort.Slice(posts, func(i, j int) bool {
+		return feedLess(posts[j], publishTime(posts[i]), posts[i].ID)
+	})
+}
+
+func publishTime(post *repo_models.Post) time.Time {
+	if post.PublishAt == nil {
+		return time.Time{}
+	}
+	return *post.PublishAt
+}
+
 func (r *PostRepository) GetPostsByUserId(ctx context.Context, limit int, offset int, userId int) ([]*repo_models.Post, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	var userPosts []*repo_models.Post
 	for _, post := range r.posts {
-		if post.UserID == userId {
+		if post.UserID == userId && post.Status == repo_models.PostStatusPublished {
 			userPosts = append(userPosts, post)
 		}
 	}
+	sortByPublishTime(userPosts)
 	start := offset
 	if start > len(userPosts) {
 		start = len(userPosts)
@@ -118,18 +265,23 @@ func (r *PostRepository) GetPostsByUserId(ctx context.Context, limit int, offset
 	result := make([]*repo_models.Post, 0, len(posts))
 	for _, post := range posts {
 		result = append(result, &repo_models.Post{
-			ID:          post.ID,
-			Title:       post.Title,
-			Content:     post.Content,
-			UserID:      post.UserID,
-			Commentable: post.Commentable,
+			ID:            post.ID,
+			Title:         post.Title,
+			Content:       post.Content,
+			ContentFormat: post.ContentFormat,
+			UserID:        post.UserID,
+			Commentable:   post.Commentable,
+			Status:        post.Status,
+			PublishAt:     post.PublishAt,
+			CommentCount:  post.CommentCount,
+			LastCommentAt: post.LastCommentAt,
 		})
 	}
 
 	return result, nil
 }
 
-func (r *PostRepository) UpdatePost(ctx context.Context, id int, title, content string, userID int, commentable bool) (*repo_models.Post, error) {
+func (r *PostRepository) UpdatePost(ctx context.Context, id int, title, content, contentFormat string, userID int, commentable bool, status string, publishAt *time.Time) (*repo_models.Post, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -140,14 +292,22 @@ func (r *PostRepository) UpdatePost(ctx context.Context, id int, title, content
 
 	post.Title = title
 	post.Content = content
+	post.ContentFormat = contentFormat
 	post.Commentable = commentable
+	post.Status = status
+	post.PublishAt = publishAt
 
 	return &repo_models.Post{
-		ID:          post.ID,
-		Title:       post.Title,
-		Content:     post.Content,
-		UserID:      post.UserID,
-		Commentable: post.Commentable,
+		ID:            post.ID,
+		Title:         post.Title,
+		Content:       post.Content,
+		ContentFormat: post.ContentFormat,
+		UserID:        post.UserID,
+		Commentable:   post.Commentable,
+		Status:        post.Status,
+		PublishAt:     post.PublishAt,
+		CommentCount:  post.CommentCount,
+		LastCommentAt: post.LastCommentAt,
 	}, nil
 }
 
@@ -163,3 +323,87 @@ func (r *PostRepository) DeletePost(ctx context.Context, id int) error {
 	delete(r.posts, id)
 	return nil
 }
+
+func (r *PostRepository) CountPostsByUserIDs(ctx context.Context, userIDs []int) (map[int]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[int]bool, len(userIDs))
+	for _, id := range userIDs {
+		wanted[id] = true
+	}
+
+	counts := make(map[int]int)
+	for _, post := range r.posts {
+		if wanted[post.UserID] && post.Status == repo_models.PostStatusPublished {
+			counts[post.UserID]++
+		}
+	}
+
+	return counts, nil
+}
+
+// DeleteUserData удаляет посты пользователя, в pg это делает каскад при удалении пользователя
+func (r *PostRepository) DeleteUserData(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, post := range r.posts {
+		if post.UserID == userID {
+			delete(r.posts, id)
+		}
+	}
+
+	return nil
+}
+
+func (r *PostRepository) GetDraftsByUserId(ctx context.Context, userId int, limit int, afterID int) ([]*repo_models.Post, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var drafts []*repo_models.Post
+	for _, post := range r.posts {
+		if post.UserID != userId {
+			continue
+		}
+		if post.Status != repo_models.PostStatusDraft && post.Status != repo_models.PostStatusScheduled {
+			continue
+		}
+		if afterID != 0 && post.ID >= afterID {
+			continue
+		}
+		drafts = append(drafts, post)
+	}
+
+	sort.Slice(drafts, func(i, j int) bool {
+		return drafts[i].ID > drafts[j].ID
+	})
+	if len(drafts) > limit {
+		drafts = drafts[:limit]
+	}
+
+	result := make([]*repo_models.Post, 0, len(drafts))
+	for _, post := range drafts {
+		copied := *post
+		result = append(result, &copied)
+	}
+
+	return result, nil
+}
+
+func (r *PostRepository) PublishDuePosts(ctx context.Context, now time.Time) ([]*repo_models.Post, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var published []*repo_models.Post
+	for _, post := range r.posts {
+		if post.Status != repo_models.PostStatusScheduled || post.PublishAt == nil || post.PublishAt.After(now) {
+			continue
+		}
+		post.Status = repo_models.PostStatusPublished
+		copied := *post
+		published = append(published, &copied)
+	}
+
+	return published, nil
+}
@@ -0,0 +1,71 @@
+package mem_repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+func TestPostsOrderedByPublishTime(t *testing.T) {
+	ctx := context.Background()
+	posts := NewPostRepository()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// id растут, а время публикации нет: запланированный пост публикуется позже, чем создан
+	create := func(userID int, status string, minutes int) int {
+		publishAt := base.Add(time.Duration(minutes) * time.Minute)
+		post, err := posts.CreatePost(ctx, "title", "text", repo_models.ContentFormatPlain, userID, true, status, &publishAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return post.ID
+	}
+	first := create(1, repo_models.PostStatusPublished, 10)
+	latest := create(1, repo_models.PostStatusPublished, 30)
+	other := create(2, repo_models.PostStatusPublished, 20)
+	sameTime := create(1, repo_models.PostStatusPublished, 10)
+	create(1, repo_models.PostStatusDraft, 40)
+
+	ids := func(page []*repo_models.Post) []int {
+		var result []int
+		for _, post := range page {
+			result = append(result, post.ID)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name   string
+		userID int
+		limit  int
+		offset int
+		want   []int
+	}{
+		{"all", 0, 10, 0, []int{latest, other, sameTime, first}},
+		{"first page", 0, 2, 0, []int{latest, other}},
+		{"second page", 0, 2, 2, []int{sameTime, first}},
+		{"author", 1, 10, 0, []int{latest, sameTime, first}},
+		{"author second page", 1, 2, 2, []int{first}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var page []*repo_models.Post
+			var err error
+			if tt.userID == 0 {
+				page, err = posts.GetPosts(ctx, tt.limit, tt.offset, "")
+			} else {
+				page, err = posts.GetPostsByUserId(ctx, tt.limit, tt.offset, tt.userID)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(page); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("posts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
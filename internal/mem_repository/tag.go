@@ -0,0 +1,223 @@
+package mem_repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+// TagRepository смотрит в PostRepository, чтобы не считать удалённые и неопубликованные посты:
+// в pg теги удалённых постов удаляет ON DELETE CASCADE.
+type TagRepository struct {
+	mu        sync.RWMutex
+	posts     *PostRepository
+	tagIDs    map[string]int
+	postTags  map[int][]string
+	followers map[string]map[int]bool
+	nextID    int
+}
+
+func NewTagRepository(posts *PostRepository) *TagRepository {
+	return &TagRepository{
+		posts:     posts,
+		tagIDs:    make(map[string]int),
+		postTags:  make(map[int][]string),
+		followers: make(map[string]map[int]bool),
+		nextID:    1,
+	}
+}
+
+func (r *TagRepository) SetPostTags(ctx context.Context, postID int, tags []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(tags) == 0 {
+		delete(r.postTags, postID)
+		return nil
+	}
+
+	for _, tag := range tags {
+		if _, exists := r.tagIDs[tag]; !exists {
+			r.tagIDs[tag] = r.nextID
+			r.nextID++
+		}
+	}
+
+	sorted := append([]string(nil), tags...)
+	sort.Strings(sorted)
+	r.postTags[postID] = sorted
+	return nil
+}
+
+func (r *TagRepository) GetTagsByPostIDs(ctx context.Context, postIDs []int) (map[int][]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tags := make(map[int][]string)
+	for _, postID := range postIDs {
+		if postTags, exists := r.postTags[postID]; exists {
+			tags[postID] = append([]string(nil), postTags...)
+		}
+	}
+
+	return tags, nil
+}
+
+func (r *TagRepository) GetPostsByTag(ctx context.Context, tag string, limit int, afterID int) ([]*repo_models.Post, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var postIDs []int
+	for postID, postTags := range r.postTags {
+		if afterID != 0 && postID >= afterID {
+			continue
+		}
+		if containsTag(postTags, tag) {
+			postIDs = append(postIDs, postID)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(postIDs)))
+
+	var posts []*repo_models.Post
+	for _, postID := range postIDs {
+		if len(posts) == limit {
+			break
+		}
+		post, err := r.posts.GetPostByID(ctx, postID)
+		if err != nil || post.Status != repo_models.PostStatusPublished {
+			continue
+		}
+		posts = append(posts, post)
+	}
+
+	return posts, nil
+}
+
+func (r *TagRepository) SearchTags(ctx context.Context, prefix string, limit int) ([]*repo_models.Tag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tags []*repo_models.Tag
+	for name := range r.tagIDs {
+		if strings.HasPrefix(name, prefix) {
+			tags = append(tags, r.tag(ctx, name))
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].PostCount != tags[j].PostCount {
+			return tags[i].PostCount > tags[j].PostCount
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+
+	return tags, nil
+}
+
+func (r *TagRepository) GetTagsByNames(ctx context.Context, names []string) ([]*repo_models.Tag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tags []*repo_models.Tag
+	for _, name := range names {
+		if _, exists := r.tagIDs[name]; exists {
+			tags = append(tags, r.tag(ctx, name))
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags, nil
+}
+
+func (r *TagRepository) FollowTag(ctx context.Context, userID int, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tagIDs[name]; !exists {
+		return fmt.Errorf("tag %q not found", name)
+	}
+
+	if r.followers[name] == nil {
+		r.followers[name] = make(map[int]bool)
+	}
+	r.followers[name][userID] = true
+	return nil
+}
+
+func (r *TagRepository) UnfollowTag(ctx context.Context, userID int, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.followers[name], userID)
+	return nil
+}
+
+func (r *TagRepository) GetFollowedTags(ctx context.Context, userID int) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var names []string
+	for name, followers := range r.followers {
+		if followers[userID] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// tag считает счётчики тега, вызывается под r.mu
+func (r *TagRepository) tag(ctx context.Context, name string) *repo_models.Tag {
+	postCount := 0
+	for postID, postTags := range r.postTags {
+		if !containsTag(postTags, name) {
+			continue
+		}
+		post, err := r.posts.GetPostByID(ctx, postID)
+		if err == nil && post.Status == repo_models.PostStatusPublished {
+			postCount++
+		}
+	}
+
+	return &repo_models.Tag{
+		ID:            r.tagIDs[name],
+		Name:          name,
+		PostCount:     postCount,
+		FollowerCount: len(r.followers[name]),
+	}
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, current := range tags {
+		if current == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteUserData удаляет подписки пользователя на теги
+func (r *TagRepository) DeleteUserData(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, followers := range r.followers {
+		delete(followers, userID)
+		if len(followers) == 0 {
+			delete(r.followers, name)
+		}
+	}
+
+	return nil
+}
@@ -2,8 +2,9 @@ package mem_repository
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/AntonCkya/ozon_habr/internal/repo_models"
 
@@ -37,7 +38,7 @@ func (r *UserRepository) CreateUser(ctx context.Context, username, password stri
 
 	for _, user := range r.users {
 		if user.Username == username {
-			return nil, errors.New("user already exists")
+			return nil, repo_models.ErrUsernameTaken
 		}
 	}
 
@@ -50,14 +51,16 @@ func (r *UserRepository) CreateUser(ctx context.Context, username, password stri
 		ID:           r.nextID,
 		Username:     username,
 		PasswordHash: string(hashedPassword),
+		CreatedAt:    time.Now(),
 	}
 
 	r.users[user.ID] = user
 	r.nextID++
 
 	return &repo_models.User{
-		ID:       user.ID,
-		Username: user.Username,
+		ID:        user.ID,
+		Username:  user.Username,
+		CreatedAt: user.CreatedAt,
 	}, nil
 }
 
@@ -67,14 +70,10 @@ func (r *UserRepository) GetUserByID(ctx context.Context, id int) (*repo_models.
 
 	user, exists := r.users[id]
 	if !exists {
-		return nil, errors.New("user not found")
+		return nil, repo_models.ErrUserNotFound
 	}
 
-	return &repo_models.User{
-		ID:           user.ID,
-		Username:     user.Username,
-		PasswordHash: user.PasswordHash,
-	}, nil
+	return copyUser(user), nil
 }
 
 func (r *UserRepository) GetUserByUsername(ctx context.Context, username string) (*repo_models.User, error) {
@@ -83,15 +82,11 @@ func (r *UserRepository) GetUserByUsername(ctx context.Context, username string)
 
 	for _, user := range r.users {
 		if user.Username == username {
-			return &repo_models.User{
-				ID:           user.ID,
-				Username:     user.Username,
-				PasswordHash: user.PasswordHash,
-			}, nil
+			return copyUser(user), nil
 		}
 	}
 
-	return nil, errors.New("user not found")
+	return nil, repo_models.ErrUserNotFound
 }
 
 func (r *UserRepository) GetUsersByIDs(ctx context.Context, ids []int) ([]*repo_models.User, error) {
@@ -101,13 +96,110 @@ func (r *UserRepository) GetUsersByIDs(ctx context.Context, ids []int) ([]*repo_
 	users := make([]*repo_models.User, 0, len(ids))
 	for _, id := range ids {
 		if user, exists := r.users[id]; exists {
-			users = append(users, &repo_models.User{
-				ID:           user.ID,
-				Username:     user.Username,
-				PasswordHash: user.PasswordHash,
-			})
+			users = append(users, copyUser(user))
 		}
 	}
 
 	return users, nil
 }
+
+func (r *UserRepository) UpdateProfile(ctx context.Context, id int, displayName, bio, avatarURL string) (*repo_models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return nil, repo_models.ErrUserNotFound
+	}
+
+	user.DisplayName = displayName
+	user.Bio = bio
+	user.AvatarURL = avatarURL
+
+	return copyUser(user), nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int, password string) (*repo_models.User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return nil, repo_models.ErrUserNotFound
+	}
+
+	user.PasswordHash = string(hashedPassword)
+	user.TokenVersion++
+
+	return copyUser(user), nil
+}
+
+func (r *UserRepository) UpdateUsername(ctx context.Context, id int, username string) (*repo_models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return nil, repo_models.ErrUserNotFound
+	}
+
+	for _, other := range r.users {
+		if other.ID != id && other.Username == username {
+			return nil, repo_models.ErrUsernameTaken
+		}
+	}
+
+	user.Username = username
+
+	return copyUser(user), nil
+}
+
+func (r *UserRepository) AnonymizeUser(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return repo_models.ErrUserNotFound
+	}
+
+	user.Username = fmt.Sprintf("deleted_%d", user.ID)
+	user.PasswordHash = ""
+	user.DisplayName = ""
+	user.Bio = ""
+	user.AvatarURL = ""
+	user.TokenVersion++
+
+	return nil
+}
+
+func (r *UserRepository) DeleteUser(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, exists := r.users[id]
+	if !exists {
+		return repo_models.ErrUserNotFound
+	}
+
+	delete(r.users, id)
+	return nil
+}
+
+func copyUser(user *repo_models.User) *repo_models.User {
+	return &repo_models.User{
+		ID:           user.ID,
+		Username:     user.Username,
+		PasswordHash: user.PasswordHash,
+		DisplayName:  user.DisplayName,
+		Bio:          user.Bio,
+		AvatarURL:    user.AvatarURL,
+		CreatedAt:    user.CreatedAt,
+		TokenVersion: user.TokenVersion,
+	}
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// имя операции задаёт клиент, поэтому число разных имён в метриках ограничено,
+// остальные попадают в "other"
+const maxOperationNames = 200
+
+// GraphQL - расширение gqlgen, считает операции, их латентность и ошибки
+type GraphQL struct {
+	names *operationNames
+}
+
+func NewGraphQL() GraphQL {
+	return GraphQL{names: &operationNames{seen: make(map[string]bool)}}
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = GraphQL{}
+
+func (g GraphQL) ExtensionName() string {
+	return "Metrics"
+}
+
+func (g GraphQL) Validate(schema graphql.ExecutableSchema) error {
+	if g.names == nil {
+		return errors.New("metrics.GraphQL must be created with NewGraphQL")
+	}
+	return nil
+}
+
+func (g GraphQL) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	response := next(ctx)
+	if response == nil {
+		return nil
+	}
+
+	for _, err := range response.Errors {
+		errorsTotal.WithLabelValues(errorCode(err)).Inc()
+	}
+
+	opCtx := graphql.GetOperationContext(ctx)
+	operationType := "unknown"
+	if opCtx.Operation != nil {
+		operationType = string(opCtx.Operation.Operation)
+	}
+	// события подписки - не отдельные операции
+	if operationType == "subscription" {
+		return response
+	}
+
+	result := "success"
+	if len(response.Errors) > 0 {
+		result = "error"
+	}
+	name := g.names.label(opCtx.OperationName)
+	operationsTotal.WithLabelValues(name, operationType, result).Inc()
+	operationDuration.WithLabelValues(name, operationType, result).Observe(time.Since(opCtx.Stats.OperationStart).Seconds())
+
+	return response
+}
+
+func errorCode(err *gqlerror.Error) string {
+	if code, ok := err.Extensions["code"].(string); ok {
+		return code
+	}
+	return "INTERNAL"
+}
+
+type operationNames struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func (n *operationNames) label(name string) string {
+	if name == "" {
+		return "anonymous"
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.seen[name] {
+		return name
+	}
+	if len(n.seen) >= maxOperationNames {
+		return "other"
+	}
+	n.seen[name] = true
+	return name
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ozon_habr"
+
+var (
+	operationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graphql_operations_total",
+		Help:      "GraphQL operations by name, type and result.",
+	}, []string{"operation", "type", "result"})
+
+	operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graphql_operation_duration_seconds",
+		Help:      "GraphQL operation latency by name, type and result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "type", "result"})
+
+	errorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graphql_errors_total",
+		Help:      "GraphQL errors by extensions.code.",
+	}, []string{"code"})
+
+	// CommentSubscriptions - активные подписки newComments по постам.
+	// Ряд поста удаляется, когда уходит его последний подписчик, так что рядов не больше, чем постов с подписчиками
+	CommentSubscriptions = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "comment_subscriptions_active",
+		Help:      "Active newComments subscriptions per post.",
+	}, []string{"post_id"})
+
+	NotificationSubscriptions = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "notification_subscriptions_active",
+		Help:      "Active notificationAdded subscriptions.",
+	})
+
+	// AuthFailures - неудачные попытки аутентификации по причине
+	AuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Authentication failures by reason.",
+	}, []string{"reason"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by handler, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler", "method", "code"})
+)
+
+// причины для AuthFailures
+const (
+	AuthMissingHeader      = "missing_header"
+	AuthInvalidHeader      = "invalid_header"
+	AuthInvalidToken       = "invalid_token"
+	AuthInvalidCredentials = "invalid_credentials"
+	AuthLoginRateLimited   = "login_rate_limited"
+)
+
+// RegisterDB добавляет статистику пула соединений sql.DB
+func RegisterDB(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler - ручка /metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// InstrumentHandler считает латентность HTTP-ручки. Hijack и Flush продолжают работать.
+func InstrumentHandler(name string, next http.Handler) http.Handler {
+	return promhttp.InstrumentHandlerDuration(httpDuration.MustCurryWith(prometheus.Labels{"handler": name}), next)
+}
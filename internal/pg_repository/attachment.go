@@ -0,0 +1,211 @@
+package pg_repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+	"github.com/lib/pq"
+)
+
+type AttachmentRepository struct {
+	db *Cluster
+}
+
+func NewAttachmentRepository(db *Cluster) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+const (
+	CreateAttachmentQuery = `
+		INSERT INTO attachments (user_id, blob_key, thumbnail_key, content_type, size, width, height)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id;
+	`
+	DetachAttachmentsQuery = `
+		UPDATE attachments
+		SET post_id = NULL
+		WHERE post_id = $1 AND NOT (id = ANY($2));
+	`
+	// чужие вложения и вложения другого поста не привязываются
+	AttachAttachmentsQuery = `
+		UPDATE attachments
+		SET post_id = $1
+		WHERE id = ANY($2) AND user_id = $3
+		AND (post_id IS NULL OR post_id = $1);
+	`
+	GetAttachmentsByPostIdsQuery = `
+		SELECT id, user_id, post_id, blob_key, thumbnail_key, content_type, size, width, height
+		FROM attachments
+		WHERE post_id = ANY($1)
+		ORDER BY post_id, id;
+	`
+	GetAttachmentsByUserIdQuery = `
+		SELECT id, user_id, post_id, blob_key, thumbnail_key, content_type, size, width, height
+		FROM attachments
+		WHERE user_id = $1;
+	`
+	DeleteAttachmentsQuery = `
+		DELETE FROM attachments
+		WHERE id = ANY($1);
+	`
+	// непривязанные вложения: загруженные и брошенные, открепленные от поста и оставшиеся от удалённых постов
+	DeleteStaleAttachmentsQuery = `
+		DELETE FROM attachments
+		WHERE post_id IS NULL AND created_at < $1
+		RETURNING id, user_id, post_id, blob_key, thumbnail_key, content_type, size, width, height;
+	`
+)
+
+func (r *AttachmentRepository) CreateAttachment(ctx context.Context, attachment *repo_models.Attachment) (*repo_models.Attachment, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	created := *attachment
+	created.PostID = nil
+	row := r.db.writer(ctx).QueryRowContext(ctx, CreateAttachmentQuery,
+		attachment.UserID,
+		attachment.Key,
+		attachment.ThumbnailKey,
+		attachment.ContentType,
+		attachment.Size,
+		attachment.Width,
+		attachment.Height,
+	)
+	if err := row.Scan(&created.ID); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// SetPostAttachments заменяет вложения поста на ids.
+// Все ids должны принадлежать userID и не быть привязаны к другому посту.
+func (r *AttachmentRepository) SetPostAttachments(ctx context.Context, postID int, userID int, ids []int) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.writer(ctx).BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, DetachAttachmentsQuery, postID, pq.Array(ids)); err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		res, err := tx.ExecContext(ctx, AttachAttachmentsQuery, postID, pq.Array(ids), userID)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if int(affected) != len(ids) {
+			return fmt.Errorf("attachment not found")
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *AttachmentRepository) GetAttachmentsByPostIDs(ctx context.Context, postIDs []int) (map[int][]*repo_models.Attachment, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	attachments := make(map[int][]*repo_models.Attachment)
+	if len(postIDs) == 0 {
+		return attachments, nil
+	}
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, GetAttachmentsByPostIdsQuery, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list, err := scanAttachments(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, attachment := range list {
+		attachments[*attachment.PostID] = append(attachments[*attachment.PostID], attachment)
+	}
+
+	return attachments, nil
+}
+
+func (r *AttachmentRepository) GetAttachmentsByUserID(ctx context.Context, userID int) ([]*repo_models.Attachment, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Primary().QueryContext(ctx, GetAttachmentsByUserIdQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAttachments(rows)
+}
+
+func (r *AttachmentRepository) DeleteAttachments(ctx context.Context, ids []int) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := r.db.writer(ctx).ExecContext(ctx, DeleteAttachmentsQuery, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteStaleAttachments удаляет не привязанные к посту вложения старше before и возвращает их,
+// чтобы удалить файлы
+func (r *AttachmentRepository) DeleteStaleAttachments(ctx context.Context, before time.Time) ([]*repo_models.Attachment, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.writer(ctx).QueryContext(ctx, DeleteStaleAttachmentsQuery, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAttachments(rows)
+}
+
+func scanAttachments(rows *sql.Rows) ([]*repo_models.Attachment, error) {
+	var attachments []*repo_models.Attachment
+	for rows.Next() {
+		var attachment repo_models.Attachment
+		err := rows.Scan(
+			&attachment.ID,
+			&attachment.UserID,
+			&attachment.PostID,
+			&attachment.Key,
+			&attachment.ThumbnailKey,
+			&attachment.ContentType,
+			&attachment.Size,
+			&attachment.Width,
+			&attachment.Height,
+		)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, &attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
@@ -0,0 +1,165 @@
+package pg_repository
+
+import (
+	"context"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+	"github.com/lib/pq"
+)
+
+type BookmarkRepository struct {
+	db *Cluster
+}
+
+func NewBookmarkRepository(db *Cluster) *BookmarkRepository {
+	return &BookmarkRepository{db: db}
+}
+
+const (
+	BookmarkPostQuery = `
+		INSERT INTO bookmarks (user_id, post_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, post_id) DO NOTHING;
+	`
+	UnbookmarkPostQuery = `
+		DELETE FROM bookmarks
+		WHERE user_id = $1 AND post_id = $2;
+	`
+	// только посты, которые пользователь может открыть: опубликованные и свои черновики;
+	// $2 - id последней закладки предыдущей страницы (0 - с начала)
+	GetBookmarksQuery = `
+		SELECT b.id, p.id, p.title, p.content, p.content_format, p.user_id, p.commentable, p.status, p.publish_at, p.comment_count, p.last_comment_at
+		FROM bookmarks b
+		JOIN posts p ON p.id = b.post_id
+		WHERE b.user_id = $1
+		AND (p.status IN ('PUBLISHED', 'ARCHIVED') OR p.user_id = $1)
+		AND ($2 = 0 OR b.id < $2)
+		ORDER BY b.id DESC
+		LIMIT $3;
+	`
+	CountBookmarksByPostIdsQuery = `
+		SELECT post_id, COUNT(*)
+		FROM bookmarks
+		WHERE post_id = ANY($1)
+		GROUP BY post_id;
+	`
+	GetBookmarkedPostIdsQuery = `
+		SELECT post_id
+		FROM bookmarks
+		WHERE user_id = $1 AND post_id = ANY($2);
+	`
+)
+
+// BookmarkPost идемпотентен: повторное добавление ничего не меняет
+func (r *BookmarkRepository) BookmarkPost(ctx context.Context, userID int, postID int) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.writer(ctx).ExecContext(ctx, BookmarkPostQuery, userID, postID)
+	return err
+}
+
+func (r *BookmarkRepository) UnbookmarkPost(ctx context.Context, userID int, postID int) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.writer(ctx).ExecContext(ctx, UnbookmarkPostQuery, userID, postID)
+	return err
+}
+
+func (r *BookmarkRepository) GetBookmarks(ctx context.Context, userID int, limit int, afterID int) ([]*repo_models.Bookmark, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, GetBookmarksQuery, userID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookmarks []*repo_models.Bookmark
+	for rows.Next() {
+		bookmark := repo_models.Bookmark{UserID: userID, Post: &repo_models.Post{}}
+		err := rows.Scan(
+			&bookmark.ID,
+			&bookmark.Post.ID,
+			&bookmark.Post.Title,
+			&bookmark.Post.Content,
+			&bookmark.Post.ContentFormat,
+			&bookmark.Post.UserID,
+			&bookmark.Post.Commentable,
+			&bookmark.Post.Status,
+			&bookmark.Post.PublishAt,
+			&bookmark.Post.CommentCount,
+			&bookmark.Post.LastCommentAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, &bookmark)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return bookmarks, nil
+}
+
+func (r *BookmarkRepository) CountBookmarksByPostIDs(ctx context.Context, postIDs []int) (map[int]int, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	counts := make(map[int]int)
+	if len(postIDs) == 0 {
+		return counts, nil
+	}
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, CountBookmarksByPostIdsQuery, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID, count int
+		if err := rows.Scan(&postID, &count); err != nil {
+			return nil, err
+		}
+		counts[postID] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// GetBookmarkedPostIDs возвращает, какие из postIDs пользователь добавил в закладки
+func (r *BookmarkRepository) GetBookmarkedPostIDs(ctx context.Context, userID int, postIDs []int) (map[int]bool, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	bookmarked := make(map[int]bool)
+	if len(postIDs) == 0 {
+		return bookmarked, nil
+	}
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, GetBookmarkedPostIdsQuery, userID, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		if err := rows.Scan(&postID); err != nil {
+			return nil, err
+		}
+		bookmarked[postID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return bookmarked, nil
+}
@@ -0,0 +1,131 @@
+package pg_repository
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cluster - основная база и реплики. Чтение идёт на реплики по кругу,
+// запись и чтение, которому нужна свежесть, - на основную.
+// После записи пользователь какое-то время читает только с основной,
+// чтобы сразу видеть свои изменения несмотря на отставание реплик.
+type Cluster struct {
+	primary  *sql.DB
+	replicas []*sql.DB
+	next     atomic.Uint64
+
+	userID       func(ctx context.Context) (int, bool)
+	stickyWindow time.Duration
+
+	mu        sync.Mutex
+	sticky    map[int]time.Time
+	lastSweep time.Time
+
+	queryTimeout time.Duration
+	stmts        *statements
+}
+
+// запрос не должен висеть дольше этого, даже если у вызывающего нет дедлайна
+const defaultQueryTimeout = 5 * time.Second
+
+// NewCluster без реплик всё отправляет в primary
+func NewCluster(primary *sql.DB, replicas ...*sql.DB) *Cluster {
+	return &Cluster{
+		primary:      primary,
+		replicas:     replicas,
+		sticky:       make(map[int]time.Time),
+		queryTimeout: defaultQueryTimeout,
+		stmts:        newStatements(),
+	}
+}
+
+// WithStickyPrimary включает чтение с основной базы в течение window после записи пользователя.
+// userID достаёт пользователя из контекста (чтобы репозитории не зависели от auth).
+func (c *Cluster) WithStickyPrimary(window time.Duration, userID func(ctx context.Context) (int, bool)) *Cluster {
+	c.stickyWindow = window
+	c.userID = userID
+	return c
+}
+
+// WithQueryTimeout задаёт таймаут одного запроса репозиториев (0 - без таймаута)
+func (c *Cluster) WithQueryTimeout(timeout time.Duration) *Cluster {
+	c.queryTimeout = timeout
+	return c
+}
+
+// Close закрывает подготовленные запросы, сами пулы закрывает тот, кто их открыл
+func (c *Cluster) Close() error {
+	return c.stmts.close()
+}
+
+func (c *Cluster) Primary() *sql.DB {
+	return c.primary
+}
+
+func (c *Cluster) Replicas() []*sql.DB {
+	return c.replicas
+}
+
+// writer - основная база, запоминает, что пользователь только что писал
+func (c *Cluster) writer(ctx context.Context) *sql.DB {
+	if userID, ok := c.currentUser(ctx); ok {
+		c.mu.Lock()
+		c.sticky[userID] = time.Now().Add(c.stickyWindow)
+		c.mu.Unlock()
+	}
+	return c.primary
+}
+
+// reader - реплика, если они есть и пользователь недавно не писал
+func (c *Cluster) reader(ctx context.Context) *sql.DB {
+	if len(c.replicas) == 0 || c.isSticky(ctx) {
+		return c.primary
+	}
+	return c.replicas[c.next.Add(1)%uint64(len(c.replicas))]
+}
+
+// withTimeout ограничивает запрос queryTimeout, более ранний дедлайн из ctx сохраняется
+func (c *Cluster) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.queryTimeout)
+}
+
+// prepared - подготовленный запрос для db, готовится при первом обращении
+func (c *Cluster) prepared(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, error) {
+	return c.stmts.get(ctx, db, query)
+}
+
+func (c *Cluster) currentUser(ctx context.Context) (int, bool) {
+	if c.userID == nil || c.stickyWindow <= 0 || len(c.replicas) == 0 {
+		return 0, false
+	}
+	return c.userID(ctx)
+}
+
+func (c *Cluster) isSticky(ctx context.Context) bool {
+	userID, ok := c.currentUser(ctx)
+	if !ok {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) > time.Minute {
+		c.lastSweep = now
+		for id, until := range c.sticky {
+			if now.After(until) {
+				delete(c.sticky, id)
+			}
+		}
+	}
+
+	until, exists := c.sticky[userID]
+	return exists && now.Before(until)
+}
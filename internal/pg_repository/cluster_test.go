@@ -0,0 +1,136 @@
+package pg_repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+type testUserKey struct{}
+
+func testUserID(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(testUserKey{}).(int)
+	return id, ok
+}
+
+func asUser(id int) context.Context {
+	return context.WithValue(context.Background(), testUserKey{}, id)
+}
+
+// openTestDBs открывает пулы без подключения: sql.Open не ходит в базу, для маршрутизации этого хватает
+func openTestDBs(t *testing.T, n int) []*sql.DB {
+	t.Helper()
+	dbs := make([]*sql.DB, n)
+	for i := range dbs {
+		db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		dbs[i] = db
+	}
+	return dbs
+}
+
+func TestClusterReaderRoundRobin(t *testing.T) {
+	dbs := openTestDBs(t, 4)
+	primary, replicas := dbs[0], dbs[1:]
+
+	tests := []struct {
+		name     string
+		replicas []*sql.DB
+		want     []*sql.DB
+	}{
+		{"no replicas", nil, []*sql.DB{primary, primary, primary}},
+		{"one replica", replicas[:1], []*sql.DB{replicas[0], replicas[0], replicas[0]}},
+		{"three replicas", replicas, []*sql.DB{replicas[1], replicas[2], replicas[0], replicas[1]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCluster(primary, tt.replicas...)
+			for i, want := range tt.want {
+				if got := c.reader(context.Background()); got != want {
+					t.Errorf("read %d went to the wrong database", i)
+				}
+			}
+			if got := c.writer(context.Background()); got != primary {
+				t.Errorf("writer is not the primary")
+			}
+		})
+	}
+}
+
+func TestClusterStickyPrimary(t *testing.T) {
+	dbs := openTestDBs(t, 2)
+	primary, replica := dbs[0], dbs[1]
+
+	tests := []struct {
+		name     string
+		window   time.Duration
+		writer   context.Context
+		reader   context.Context
+		expire   bool
+		wantRead *sql.DB
+	}{
+		{"writer reads from primary", time.Minute, asUser(1), asUser(1), false, primary},
+		{"other user reads from replica", time.Minute, asUser(1), asUser(2), false, replica},
+		{"anonymous write does not stick", time.Minute, context.Background(), asUser(1), false, replica},
+		{"anonymous read goes to replica", time.Minute, asUser(1), context.Background(), false, replica},
+		{"window expired", time.Minute, asUser(1), asUser(1), true, replica},
+		{"sticky disabled", 0, asUser(1), asUser(1), false, replica},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCluster(primary, replica).WithStickyPrimary(tt.window, testUserID)
+
+			if got := c.writer(tt.writer); got != primary {
+				t.Fatalf("writer is not the primary")
+			}
+			if tt.expire {
+				c.mu.Lock()
+				for id := range c.sticky {
+					c.sticky[id] = time.Now().Add(-time.Second)
+				}
+				c.mu.Unlock()
+			}
+
+			if got := c.reader(tt.reader); got != tt.wantRead {
+				t.Errorf("read went to the wrong database")
+			}
+		})
+	}
+}
+
+func TestClusterWithTimeout(t *testing.T) {
+	dbs := openTestDBs(t, 1)
+
+	tests := []struct {
+		name         string
+		timeout      time.Duration
+		wantDeadline bool
+	}{
+		{"default", defaultQueryTimeout, true},
+		{"custom", time.Second, true},
+		{"disabled", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCluster(dbs[0]).WithQueryTimeout(tt.timeout)
+
+			ctx, cancel := c.withTimeout(context.Background())
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			if ok != tt.wantDeadline {
+				t.Fatalf("has deadline = %v, want %v", ok, tt.wantDeadline)
+			}
+			if ok && time.Until(deadline) > tt.timeout {
+				t.Errorf("deadline is %v away, want at most %v", time.Until(deadline), tt.timeout)
+			}
+		})
+	}
+}
@@ -9,65 +9,86 @@ import (
 )
 
 type CommentRepository struct {
-	db *sql.DB
+	db *Cluster
+	// сколько последних событий хранить в журнале поста
+	eventLogSize int
 }
 
-func NewCommentRepository(db *sql.DB) *CommentRepository {
-	return &CommentRepository{db: db}
+func NewCommentRepository(db *Cluster, eventLogSize int) *CommentRepository {
+	return &CommentRepository{db: db, eventLogSize: eventLogSize}
 }
 
 const (
 	CreateCommentQuery = `
-		INSERT INTO comments (content, user_id, post_id, parent_id) 
-		VALUES ($1, $2, $3, $4) 
-	    RETURNING id, content, user_id, post_id, parent_id;
+		INSERT INTO comments (content, user_id, post_id, parent_id, content_format)
+		VALUES ($1, $2, $3, $4, $5)
+	    RETURNING id, content, content_format, user_id, post_id, parent_id;
 	`
 	GetCommentsByPostIdQuery = `
-		SELECT id, content, user_id, post_id, parent_id
+		SELECT id, content, content_format, user_id, post_id, parent_id
 	    FROM comments
 		WHERE post_id = $1
 		LIMIT $2
 		OFFSET $3;
 	`
 	GetCommentsByPostIdBulkQuery = `
-		SELECT id, content, user_id, post_id, parent_id
+		SELECT id, content, content_format, user_id, post_id, parent_id
 	    FROM comments
 		WHERE post_id = ANY($1);
 	`
 	GetRepliesQuery = `
-		SELECT id, content, user_id, post_id, parent_id
+		SELECT id, content, content_format, user_id, post_id, parent_id
 	    FROM comments
 		WHERE parent_id = $1;
 	`
 	UpdateCommentQuery = `
 		UPDATE comments
 		SET
-		content = $2
+		content = $2,
+		content_format = $3
 	    WHERE id = $1
-	    RETURNING id, content, user_id, post_id, parent_id;
+	    RETURNING id, content, content_format, user_id, post_id, parent_id;
 	`
 	DeleteCommentQuery = `
 		DELETE FROM comments
 		WHERE id = $1;
 	`
 	GetCommentQuery = `
-		SELECT id, content, user_id, post_id, parent_id
+		SELECT id, content, content_format, user_id, post_id, parent_id
 	    FROM comments
 		WHERE id = $1;
 	`
+	CountCommentsByUserIdsQuery = `
+		SELECT user_id, COUNT(*)
+		FROM comments
+		WHERE user_id = ANY($1)
+		GROUP BY user_id;
+	`
 )
 
-func (r *CommentRepository) CreateComment(ctx context.Context, content string, userID, postID, parentID int) (*repo_models.Comment, error) {
+// CreateComment создаёт комментарий и событие в журнале поста в одной транзакции,
+// чтобы досылка пропущенных комментариев не теряла их
+func (r *CommentRepository) CreateComment(ctx context.Context, content, contentFormat string, userID, postID, parentID int) (*repo_models.Comment, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.writer(ctx).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	var comment repo_models.Comment
 	var row *sql.Row
 	if parentID == -1 {
-		row = r.db.QueryRowContext(ctx, CreateCommentQuery, content, userID, postID, nil)
+		row = tx.QueryRowContext(ctx, CreateCommentQuery, content, userID, postID, nil, contentFormat)
 	} else {
-		row = r.db.QueryRowContext(ctx, CreateCommentQuery, content, userID, postID, parentID)
+		row = tx.QueryRowContext(ctx, CreateCommentQuery, content, userID, postID, parentID, contentFormat)
 	}
-	err := row.Scan(
+	err = row.Scan(
 		&comment.ID,
 		&comment.Content,
+		&comment.ContentFormat,
 		&comment.UserID,
 		&comment.PostID,
 		&comment.ParentID,
@@ -76,93 +97,75 @@ func (r *CommentRepository) CreateComment(ctx context.Context, content string, u
 		return nil, err
 	}
 
+	err = tx.QueryRowContext(ctx, CreateCommentEventQuery, comment.PostID, comment.ID).Scan(&comment.EventID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, TrimCommentEventsQuery, comment.PostID, r.eventLogSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
 	return &comment, nil
 }
 
 func (r *CommentRepository) GetCommentsByPostID(ctx context.Context, postID int, limit int, offset int) ([]*repo_models.Comment, error) {
-	rows, err := r.db.QueryContext(ctx, GetCommentsByPostIdQuery, postID, limit, offset)
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, GetCommentsByPostIdQuery, postID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var comments []*repo_models.Comment
-	for rows.Next() {
-		var comment repo_models.Comment
-		err := rows.Scan(
-			&comment.ID,
-			&comment.Content,
-			&comment.UserID,
-			&comment.PostID,
-			&comment.ParentID,
-		)
-		if err != nil {
-			return nil, err
-		}
-		comments = append(comments, &comment)
-	}
-
-	return comments, nil
+	return scanComments(rows)
 }
 
 func (r *CommentRepository) GetCommentsByPostIDs(ctx context.Context, postIDs []int) ([]*repo_models.Comment, error) {
-	rows, err := r.db.QueryContext(ctx, GetCommentsByPostIdBulkQuery, pq.Array(postIDs))
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	stmt, err := r.db.prepared(ctx, r.db.reader(ctx), GetCommentsByPostIdBulkQuery)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var comments []*repo_models.Comment
-	for rows.Next() {
-		var comment repo_models.Comment
-		err := rows.Scan(
-			&comment.ID,
-			&comment.Content,
-			&comment.UserID,
-			&comment.PostID,
-			&comment.ParentID,
-		)
-		if err != nil {
-			return nil, err
-		}
-		comments = append(comments, &comment)
+	rows, err := stmt.QueryContext(ctx, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return comments, nil
+	return scanComments(rows)
 }
 
 func (r *CommentRepository) GetReplies(ctx context.Context, parentID int) ([]*repo_models.Comment, error) {
-	rows, err := r.db.QueryContext(ctx, GetRepliesQuery, parentID)
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, GetRepliesQuery, parentID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var comments []*repo_models.Comment
-	for rows.Next() {
-		var comment repo_models.Comment
-		err := rows.Scan(
-			&comment.ID,
-			&comment.Content,
-			&comment.UserID,
-			&comment.PostID,
-			&comment.ParentID,
-		)
-		if err != nil {
-			return nil, err
-		}
-		comments = append(comments, &comment)
-	}
-
-	return comments, nil
+	return scanComments(rows)
 }
 
 func (r *CommentRepository) GetCommentByID(ctx context.Context, id int) (*repo_models.Comment, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
 	var comment repo_models.Comment
-	row := r.db.QueryRowContext(ctx, GetCommentQuery, id)
+	row := r.db.Primary().QueryRowContext(ctx, GetCommentQuery, id)
 	err := row.Scan(
 		&comment.ID,
 		&comment.Content,
+		&comment.ContentFormat,
 		&comment.UserID,
 		&comment.PostID,
 		&comment.ParentID,
@@ -174,12 +177,16 @@ func (r *CommentRepository) GetCommentByID(ctx context.Context, id int) (*repo_m
 	return &comment, nil
 }
 
-func (r *CommentRepository) UpdateComment(ctx context.Context, id int, content string) (*repo_models.Comment, error) {
+func (r *CommentRepository) UpdateComment(ctx context.Context, id int, content, contentFormat string) (*repo_models.Comment, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
 	var comment repo_models.Comment
-	row := r.db.QueryRowContext(ctx, UpdateCommentQuery, id, content)
+	row := r.db.writer(ctx).QueryRowContext(ctx, UpdateCommentQuery, id, content, contentFormat)
 	err := row.Scan(
 		&comment.ID,
 		&comment.Content,
+		&comment.ContentFormat,
 		&comment.UserID,
 		&comment.PostID,
 		&comment.ParentID,
@@ -192,10 +199,68 @@ func (r *CommentRepository) UpdateComment(ctx context.Context, id int, content s
 }
 
 func (r *CommentRepository) DeleteComment(ctx context.Context, id int) error {
-	_, err := r.db.ExecContext(ctx, DeleteCommentQuery, id)
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.writer(ctx).ExecContext(ctx, DeleteCommentQuery, id)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// CountCommentsByUserIDs возвращает число комментариев каждого из пользователей, у кого их нет - в карте отсутствуют
+func (r *CommentRepository) CountCommentsByUserIDs(ctx context.Context, userIDs []int) (map[int]int, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	counts := make(map[int]int)
+	if len(userIDs) == 0 {
+		return counts, nil
+	}
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, CountCommentsByUserIdsQuery, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, count int
+		if err := rows.Scan(&userID, &count); err != nil {
+			return nil, err
+		}
+		counts[userID] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func scanComments(rows *sql.Rows) ([]*repo_models.Comment, error) {
+	var comments []*repo_models.Comment
+	for rows.Next() {
+		var comment repo_models.Comment
+		err := rows.Scan(
+			&comment.ID,
+			&comment.Content,
+			&comment.ContentFormat,
+			&comment.UserID,
+			&comment.PostID,
+			&comment.ParentID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, &comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
@@ -0,0 +1,80 @@
+package pg_repository
+
+import (
+	"context"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+// CommentEventRepository читает журнал событий, пишет его CommentRepository.CreateComment
+// в одной транзакции с комментарием
+type CommentEventRepository struct {
+	db *Cluster
+}
+
+func NewCommentEventRepository(db *Cluster) *CommentEventRepository {
+	return &CommentEventRepository{db: db}
+}
+
+const (
+	CreateCommentEventQuery = `
+		INSERT INTO comment_events (post_id, comment_id)
+		VALUES ($1, $2)
+		RETURNING id;
+	`
+	// оставляем в журнале поста только последние $2 событий
+	TrimCommentEventsQuery = `
+		DELETE FROM comment_events
+		WHERE post_id = $1 AND id <= (
+			SELECT id
+			FROM comment_events
+			WHERE post_id = $1
+			ORDER BY id DESC
+			OFFSET $2
+			LIMIT 1
+		);
+	`
+	GetCommentEventsSinceQuery = `
+		SELECT e.id, c.id, c.content, c.content_format, c.user_id, c.post_id, c.parent_id
+		FROM comment_events e
+		JOIN comments c ON c.id = e.comment_id
+		WHERE e.post_id = $1 AND e.id > $2
+		ORDER BY e.id;
+	`
+)
+
+func (r *CommentEventRepository) GetCommentEventsSince(ctx context.Context, postID int, sinceID int) ([]*repo_models.CommentEvent, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.Primary().QueryContext(ctx, GetCommentEventsSinceQuery, postID, sinceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*repo_models.CommentEvent
+	for rows.Next() {
+		var comment repo_models.Comment
+		event := repo_models.CommentEvent{PostID: postID, Comment: &comment}
+		err := rows.Scan(
+			&event.ID,
+			&comment.ID,
+			&comment.Content,
+			&comment.ContentFormat,
+			&comment.UserID,
+			&comment.PostID,
+			&comment.ParentID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,175 @@
+package pg_repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+type FollowRepository struct {
+	db *Cluster
+}
+
+func NewFollowRepository(db *Cluster) *FollowRepository {
+	return &FollowRepository{db: db}
+}
+
+const (
+	FollowUserQuery = `
+		INSERT INTO follows (follower_id, followee_id)
+		VALUES ($1, $2)
+		ON CONFLICT (follower_id, followee_id) DO NOTHING;
+	`
+	UnfollowUserQuery = `
+		DELETE FROM follows
+		WHERE follower_id = $1 AND followee_id = $2;
+	`
+	// $2 - id последней подписки предыдущей страницы (0 - с начала)
+	GetFollowersQuery = `
+		SELECT id, follower_id, followee_id
+		FROM follows
+		WHERE followee_id = $1
+		AND ($2 = 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3;
+	`
+	GetFollowingQuery = `
+		SELECT id, follower_id, followee_id
+		FROM follows
+		WHERE follower_id = $1
+		AND ($2 = 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3;
+	`
+	CountFollowersQuery = `
+		SELECT COUNT(*) FROM follows WHERE followee_id = $1;
+	`
+	CountFollowingQuery = `
+		SELECT COUNT(*) FROM follows WHERE follower_id = $1;
+	`
+	// Лента - объединение двух источников, каждый из которых сам идёт по индексу от новых постов
+	// к старым и останавливается на $4: посты авторов из подписок (idx_posts_user_published_at)
+	// и посты с тегами из подписок (idx_posts_published_at). Пост, попавший в оба, берётся один раз.
+	// Порядок и курсор - (publish_at, id), $2 и $3 - последний пост предыдущей страницы ($3 = 0 - с начала).
+	GetFeedQuery = `
+		SELECT p.id, p.title, p.content, p.content_format, p.user_id, p.commentable, p.status, p.publish_at, p.comment_count, p.last_comment_at
+		FROM posts p
+		WHERE p.id IN (
+			(SELECT fp.id
+			FROM follows f
+			JOIN posts fp ON fp.user_id = f.followee_id
+			WHERE f.follower_id = $1 AND fp.status = 'PUBLISHED'
+			AND ($3 = 0 OR (fp.publish_at, fp.id) < ($2, $3))
+			ORDER BY fp.publish_at DESC, fp.id DESC
+			LIMIT $4)
+			UNION
+			(SELECT tp.id
+			FROM tag_followers tf
+			JOIN post_tags pt ON pt.tag_id = tf.tag_id
+			JOIN posts tp ON tp.id = pt.post_id
+			WHERE tf.user_id = $1 AND tp.status = 'PUBLISHED'
+			AND ($3 = 0 OR (tp.publish_at, tp.id) < ($2, $3))
+			ORDER BY tp.publish_at DESC, tp.id DESC
+			LIMIT $4)
+		)
+		ORDER BY p.publish_at DESC, p.id DESC
+		LIMIT $4;
+	`
+)
+
+// FollowUser идемпотентен: повторная подписка ничего не меняет
+func (r *FollowRepository) FollowUser(ctx context.Context, followerID int, userID int) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.writer(ctx).ExecContext(ctx, FollowUserQuery, followerID, userID)
+	return err
+}
+
+func (r *FollowRepository) UnfollowUser(ctx context.Context, followerID int, userID int) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.writer(ctx).ExecContext(ctx, UnfollowUserQuery, followerID, userID)
+	return err
+}
+
+func (r *FollowRepository) GetFollowers(ctx context.Context, userID int, limit int, afterID int) ([]*repo_models.Follow, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, GetFollowersQuery, userID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFollows(rows)
+}
+
+func (r *FollowRepository) GetFollowing(ctx context.Context, userID int, limit int, afterID int) ([]*repo_models.Follow, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, GetFollowingQuery, userID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFollows(rows)
+}
+
+func (r *FollowRepository) CountFollowers(ctx context.Context, userID int) (int, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := r.db.reader(ctx).QueryRowContext(ctx, CountFollowersQuery, userID).Scan(&count)
+	return count, err
+}
+
+func (r *FollowRepository) CountFollowing(ctx context.Context, userID int) (int, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := r.db.reader(ctx).QueryRowContext(ctx, CountFollowingQuery, userID).Scan(&count)
+	return count, err
+}
+
+func (r *FollowRepository) GetFeed(ctx context.Context, userID int, limit int, after *repo_models.FeedCursor) ([]*repo_models.Post, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var cursor repo_models.FeedCursor
+	if after != nil {
+		cursor = *after
+	}
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, GetFeedQuery, userID, cursor.PublishAt, cursor.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+func scanFollows(rows *sql.Rows) ([]*repo_models.Follow, error) {
+	var follows []*repo_models.Follow
+	for rows.Next() {
+		var follow repo_models.Follow
+		if err := rows.Scan(&follow.ID, &follow.FollowerID, &follow.UserID); err != nil {
+			return nil, err
+		}
+		follows = append(follows, &follow)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return follows, nil
+}
@@ -0,0 +1,129 @@
+package pg_repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+)
+
+type LoginAttemptRepository struct {
+	db *Cluster
+}
+
+func NewLoginAttemptRepository(db *Cluster) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+const (
+	GetLoginAttemptsQuery = `
+		SELECT key, failures, last_failure_at, locked_until
+		FROM login_attempts
+		WHERE key = $1;
+	`
+	AddLoginFailureQuery = `
+		INSERT INTO login_attempts (key, failures, last_failure_at, locked_until)
+		VALUES ($1, 1, $2, $2)
+		ON CONFLICT (key) DO UPDATE
+		SET
+		failures = CASE WHEN login_attempts.last_failure_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+		last_failure_at = EXCLUDED.last_failure_at
+		RETURNING key, failures, last_failure_at, locked_until;
+	`
+	LockLoginAttemptsQuery = `
+		UPDATE login_attempts
+		SET locked_until = GREATEST(locked_until, $2)
+		WHERE key = $1;
+	`
+	ResetLoginAttemptsQuery = `
+		DELETE FROM login_attempts
+		WHERE key = $1;
+	`
+	DeleteStaleLoginAttemptsQuery = `
+		DELETE FROM login_attempts
+		WHERE last_failure_at < $1 AND locked_until < $2;
+	`
+)
+
+func (r *LoginAttemptRepository) GetLoginAttempts(ctx context.Context, key string) (*repo_models.LoginAttempts, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var attempts repo_models.LoginAttempts
+	row := r.db.Primary().QueryRowContext(ctx, GetLoginAttemptsQuery, key)
+	err := row.Scan(
+		&attempts.Key,
+		&attempts.Failures,
+		&attempts.LastFailureAt,
+		&attempts.LockedUntil,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &attempts, nil
+}
+
+func (r *LoginAttemptRepository) AddLoginFailure(ctx context.Context, key string, now time.Time, windowStart time.Time) (*repo_models.LoginAttempts, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var attempts repo_models.LoginAttempts
+	row := r.db.Primary().QueryRowContext(ctx, AddLoginFailureQuery, key, now, windowStart)
+	err := row.Scan(
+		&attempts.Key,
+		&attempts.Failures,
+		&attempts.LastFailureAt,
+		&attempts.LockedUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &attempts, nil
+}
+
+func (r *LoginAttemptRepository) LockLoginAttempts(ctx context.Context, key string, until time.Time) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.Primary().ExecContext(ctx, LockLoginAttemptsQuery, key, until)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *LoginAttemptRepository) ResetLoginAttempts(ctx context.Context, key string) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.Primary().ExecContext(ctx, ResetLoginAttemptsQuery, key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *LoginAttemptRepository) DeleteStaleLoginAttempts(ctx context.Context, before time.Time, now time.Time) (int, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.Primary().ExecContext(ctx, DeleteStaleLoginAttemptsQuery, before, now)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(deleted), nil
+}
@@ -0,0 +1,127 @@
+package pg_repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+	"github.com/lib/pq"
+)
+
+type NotificationRepository struct {
+	db *Cluster
+}
+
+func NewNotificationRepository(db *Cluster) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+const (
+	CreateNotificationQuery = `
+		INSERT INTO notifications (user_id, actor_id, type, post_id, comment_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, user_id, actor_id, type, post_id, comment_id, read, created_at;
+	`
+	// keyset-пагинация: $2 - id последнего уведомления предыдущей страницы (0 - с начала)
+	GetNotificationsQuery = `
+		SELECT id, user_id, actor_id, type, post_id, comment_id, read, created_at
+		FROM notifications
+		WHERE user_id = $1
+		AND ($2 = 0 OR id < $2)
+		AND (NOT $3 OR NOT read)
+		ORDER BY id DESC
+		LIMIT $4;
+	`
+	MarkNotificationsReadQuery = `
+		UPDATE notifications
+		SET read = TRUE
+		WHERE user_id = $1 AND NOT read AND id = ANY($2);
+	`
+	MarkAllNotificationsReadQuery = `
+		UPDATE notifications
+		SET read = TRUE
+		WHERE user_id = $1 AND NOT read;
+	`
+)
+
+func (r *NotificationRepository) CreateNotification(ctx context.Context, userID, actorID int, notificationType string, postID, commentID int) (*repo_models.Notification, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var notification repo_models.Notification
+	row := r.db.writer(ctx).QueryRowContext(ctx, CreateNotificationQuery, userID, actorID, notificationType, postID, commentID)
+	err := row.Scan(
+		&notification.ID,
+		&notification.UserID,
+		&notification.ActorID,
+		&notification.Type,
+		&notification.PostID,
+		&notification.CommentID,
+		&notification.Read,
+		&notification.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &notification, nil
+}
+
+func (r *NotificationRepository) GetNotifications(ctx context.Context, userID int, limit int, afterID int, unreadOnly bool) ([]*repo_models.Notification, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, GetNotificationsQuery, userID, afterID, unreadOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*repo_models.Notification
+	for rows.Next() {
+		var notification repo_models.Notification
+		err := rows.Scan(
+			&notification.ID,
+			&notification.UserID,
+			&notification.ActorID,
+			&notification.Type,
+			&notification.PostID,
+			&notification.CommentID,
+			&notification.Read,
+			&notification.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, &notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (r *NotificationRepository) MarkNotificationsRead(ctx context.Context, userID int, ids []int) (int, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var res sql.Result
+	var err error
+	if ids == nil {
+		res, err = r.db.writer(ctx).ExecContext(ctx, MarkAllNotificationsReadQuery, userID)
+	} else {
+		res, err = r.db.writer(ctx).ExecContext(ctx, MarkNotificationsReadQuery, userID, pq.Array(ids))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	marked, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(marked), nil
+}
@@ -0,0 +1,59 @@
+package pg_repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/AntonCkya/ozon_habr/internal/logging"
+)
+
+// PersistedQueryRepository - общее для всех инстансов хранилище APQ, реализует graphql.Cache[string]
+type PersistedQueryRepository struct {
+	db *Cluster
+}
+
+func NewPersistedQueryRepository(db *Cluster) *PersistedQueryRepository {
+	return &PersistedQueryRepository{db: db}
+}
+
+const (
+	GetPersistedQueryQuery = `
+		SELECT query
+		FROM persisted_queries
+		WHERE hash = $1;
+	`
+	AddPersistedQueryQuery = `
+		INSERT INTO persisted_queries (hash, query)
+		VALUES ($1, $2)
+		ON CONFLICT (hash) DO NOTHING;
+	`
+)
+
+// интерфейс кэша не возвращает ошибок, поэтому они только логируются,
+// а запрос ведёт себя как промах кэша
+func (r *PersistedQueryRepository) Get(ctx context.Context, hash string) (string, bool) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var query string
+	err := r.db.Primary().QueryRowContext(ctx, GetPersistedQueryQuery, hash).Scan(&query)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to get persisted query", "hash", hash, "error", err)
+		return "", false
+	}
+
+	return query, true
+}
+
+func (r *PersistedQueryRepository) Add(ctx context.Context, hash string, query string) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := r.db.Primary().ExecContext(ctx, AddPersistedQueryQuery, hash, query); err != nil {
+		logging.FromContext(ctx).Error("failed to save persisted query", "hash", hash, "error", err)
+	}
+}
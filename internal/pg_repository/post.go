@@ -3,39 +3,60 @@ package pg_repository
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+	"github.com/lib/pq"
 )
 
 type PostRepository struct {
-	db *sql.DB
+	db *Cluster
 }
 
-func NewPostRepository(db *sql.DB) *PostRepository {
+func NewPostRepository(db *Cluster) *PostRepository {
 	return &PostRepository{db: db}
 }
 
 const (
 	CreatePostQuery = `
-		INSERT INTO posts (title, content, user_id, commentable)
-		VALUES ($1, $2, $3, $4)
-	    RETURNING id, title, content, user_id, commentable;
+		INSERT INTO posts (title, content, user_id, commentable, status, publish_at, content_format)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	    RETURNING id, title, content, content_format, user_id, commentable, status, publish_at, comment_count, last_comment_at;
 	`
 	GetPostByIdQuery = `
-		SELECT id, title, content, user_id, commentable
+		SELECT id, title, content, content_format, user_id, commentable, status, publish_at, comment_count, last_comment_at
 		FROM posts
 		WHERE id = $1;
 	`
 	GetPostsByUserIdQuery = `
-		SELECT id, title, content, user_id, commentable
+		SELECT id, title, content, content_format, user_id, commentable, status, publish_at, comment_count, last_comment_at
 		FROM posts
-		WHERE user_id = $1
+		WHERE user_id = $1 AND status = 'PUBLISHED'
+		ORDER BY publish_at DESC, id DESC
 		LIMIT $2
 		OFFSET $3;
 	`
 	GetPostsQuery = `
-		SELECT id, title, content, user_id, commentable
+		SELECT id, title, content, content_format, user_id, commentable, status, publish_at, comment_count, last_comment_at
 		FROM posts
+		WHERE status = 'PUBLISHED'
+		ORDER BY publish_at DESC, id DESC
+		LIMIT $1
+		OFFSET $2;
+	`
+	GetMostDiscussedPostsQuery = `
+		SELECT id, title, content, content_format, user_id, commentable, status, publish_at, comment_count, last_comment_at
+		FROM posts
+		WHERE status = 'PUBLISHED'
+		ORDER BY comment_count DESC, id DESC
+		LIMIT $1
+		OFFSET $2;
+	`
+	GetRecentlyDiscussedPostsQuery = `
+		SELECT id, title, content, content_format, user_id, commentable, status, publish_at, comment_count, last_comment_at
+		FROM posts
+		WHERE status = 'PUBLISHED'
+		ORDER BY last_comment_at DESC NULLS LAST, id DESC
 		LIMIT $1
 		OFFSET $2;
 	`
@@ -44,26 +65,76 @@ const (
 		SET
 		title = $1,
 		content = $2,
-		commentable = $5
+		commentable = $5,
+		status = $6,
+		publish_at = $7,
+		content_format = $8
 	    WHERE id = $3 AND user_id = $4
-	    RETURNING id, title, content, user_id, commentable;
+	    RETURNING id, title, content, content_format, user_id, commentable, status, publish_at, comment_count, last_comment_at;
 	`
 	DeletePostQuery = `
 		DELETE FROM posts
 		WHERE id = $1;
 	`
+	CountPostsByUserIdsQuery = `
+		SELECT user_id, COUNT(*)
+		FROM posts
+		WHERE user_id = ANY($1) AND status = 'PUBLISHED'
+		GROUP BY user_id;
+	`
+	// черновики и запланированные посты автора, keyset-пагинация как у уведомлений
+	GetDraftsByUserIdQuery = `
+		SELECT id, title, content, content_format, user_id, commentable, status, publish_at, comment_count, last_comment_at
+		FROM posts
+		WHERE user_id = $1 AND status IN ('DRAFT', 'SCHEDULED')
+		AND ($2 = 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3;
+	`
+	// пересчёт счётчиков комментариев; таблица comments блокируется от записи,
+	// чтобы триггер не изменил счётчик между подсчётом и обновлением
+	LockCommentsQuery = `
+		LOCK TABLE comments IN SHARE MODE;
+	`
+	RepairCommentStatsQuery = `
+		UPDATE posts p
+		SET comment_count = s.comment_count, last_comment_at = s.last_comment_at
+		FROM (
+			SELECT p2.id, COUNT(c.id) AS comment_count, MAX(c.created_at) AS last_comment_at
+			FROM posts p2
+			LEFT JOIN comments c ON c.post_id = p2.id
+			GROUP BY p2.id
+		) s
+		WHERE p.id = s.id
+		AND (p.comment_count <> s.comment_count OR p.last_comment_at IS DISTINCT FROM s.last_comment_at);
+	`
+	// UPDATE атомарен, поэтому при нескольких инстансах каждый пост публикует ровно один
+	PublishDuePostsQuery = `
+		UPDATE posts
+		SET status = 'PUBLISHED'
+		WHERE status = 'SCHEDULED' AND publish_at <= $1
+		RETURNING id, title, content, content_format, user_id, commentable, status, publish_at, comment_count, last_comment_at;
+	`
 )
 
-func (r *PostRepository) CreatePost(ctx context.Context, title, content string, userID int, commentable bool) (*repo_models.Post, error) {
+func (r *PostRepository) CreatePost(ctx context.Context, title, content, contentFormat string, userID int, commentable bool, status string, publishAt *time.Time) (*repo_models.Post, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
 	var post repo_models.Post
 
-	row := r.db.QueryRowContext(ctx, CreatePostQuery, title, content, userID, commentable)
+	row := r.db.writer(ctx).QueryRowContext(ctx, CreatePostQuery, title, content, userID, commentable, status, publishAt, contentFormat)
 	err := row.Scan(
 		&post.ID,
 		&post.Title,
 		&post.Content,
+		&post.ContentFormat,
 		&post.UserID,
 		&post.Commentable,
+		&post.Status,
+		&post.PublishAt,
+		&post.CommentCount,
+		&post.LastCommentAt,
 	)
 	if err != nil {
 		return nil, err
@@ -73,14 +144,85 @@ func (r *PostRepository) CreatePost(ctx context.Context, title, content string,
 }
 
 func (r *PostRepository) GetPostByID(ctx context.Context, id int) (*repo_models.Post, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
 	var post repo_models.Post
-	row := r.db.QueryRowContext(ctx, GetPostByIdQuery, id)
+	stmt, err := r.db.prepared(ctx, r.db.reader(ctx), GetPostByIdQuery)
+	if err != nil {
+		return nil, err
+	}
+	row := stmt.QueryRowContext(ctx, id)
+	err = row.Scan(
+		&post.ID,
+		&post.Title,
+		&post.Content,
+		&post.ContentFormat,
+		&post.UserID,
+		&post.Commentable,
+		&post.Status,
+		&post.PublishAt,
+		&post.CommentCount,
+		&post.LastCommentAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &post, nil
+}
+
+func (r *PostRepository) GetPosts(ctx context.Context, limit int, offset int, order string) ([]*repo_models.Post, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	query := GetPostsQuery
+	switch order {
+	case repo_models.PostOrderMostDiscussed:
+		query = GetMostDiscussedPostsQuery
+	case repo_models.PostOrderRecentlyDiscussed:
+		query = GetRecentlyDiscussedPostsQuery
+	}
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+func (r *PostRepository) GetPostsByUserId(ctx context.Context, limit int, offset int, userId int) ([]*repo_models.Post, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, GetPostsByUserIdQuery, userId, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+func (r *PostRepository) UpdatePost(ctx context.Context, id int, title, content, contentFormat string, userID int, commentable bool, status string, publishAt *time.Time) (*repo_models.Post, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var post repo_models.Post
+	row := r.db.writer(ctx).QueryRowContext(ctx, UpdatePostQuery, title, content, id, userID, commentable, status, publishAt, contentFormat)
 	err := row.Scan(
 		&post.ID,
 		&post.Title,
 		&post.Content,
+		&post.ContentFormat,
 		&post.UserID,
 		&post.Commentable,
+		&post.Status,
+		&post.PublishAt,
+		&post.CommentCount,
+		&post.LastCommentAt,
 	)
 	if err != nil {
 		return nil, err
@@ -89,39 +231,101 @@ func (r *PostRepository) GetPostByID(ctx context.Context, id int) (*repo_models.
 	return &post, nil
 }
 
-func (r *PostRepository) GetPosts(ctx context.Context, limit int, offset int) ([]*repo_models.Post, error) {
-	rows, err := r.db.QueryContext(ctx, GetPostsQuery, limit, offset)
+func (r *PostRepository) DeletePost(ctx context.Context, id int) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.writer(ctx).ExecContext(ctx, DeletePostQuery, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CountPostsByUserIDs возвращает число опубликованных постов каждого из авторов, у кого их нет - в карте отсутствуют
+func (r *PostRepository) CountPostsByUserIDs(ctx context.Context, userIDs []int) (map[int]int, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	counts := make(map[int]int)
+	if len(userIDs) == 0 {
+		return counts, nil
+	}
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, CountPostsByUserIdsQuery, pq.Array(userIDs))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []*repo_models.Post
 	for rows.Next() {
-		var post repo_models.Post
-		err := rows.Scan(
-			&post.ID,
-			&post.Title,
-			&post.Content,
-			&post.UserID,
-			&post.Commentable,
-		)
-		if err != nil {
+		var userID, count int
+		if err := rows.Scan(&userID, &count); err != nil {
 			return nil, err
 		}
-		posts = append(posts, &post)
+		counts[userID] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return posts, nil
+	return counts, nil
 }
 
-func (r *PostRepository) GetPostsByUserId(ctx context.Context, limit int, offset int, userId int) ([]*repo_models.Post, error) {
-	rows, err := r.db.QueryContext(ctx, GetPostsByUserIdQuery, userId, limit, offset)
+func (r *PostRepository) GetDraftsByUserId(ctx context.Context, userId int, limit int, afterID int) ([]*repo_models.Post, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	// черновики видит только автор сразу после сохранения, поэтому читаем с основной базы
+	rows, err := r.db.Primary().QueryContext(ctx, GetDraftsByUserIdQuery, userId, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+func (r *PostRepository) PublishDuePosts(ctx context.Context, now time.Time) ([]*repo_models.Post, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.writer(ctx).QueryContext(ctx, PublishDuePostsQuery, now)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanPosts(rows)
+}
+
+// RepairCommentStats пересчитывает comment_count и last_comment_at по таблице comments
+// и возвращает, у скольких постов они разошлись.
+// Без withTimeout: на большой базе пересчёт идёт дольше DB_QUERY_TIMEOUT.
+func (r *PostRepository) RepairCommentStats(ctx context.Context) (int, error) {
+	tx, err := r.db.Primary().BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, LockCommentsQuery); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, RepairCommentStatsQuery)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), tx.Commit()
+}
+
+func scanPosts(rows *sql.Rows) ([]*repo_models.Post, error) {
 	var posts []*repo_models.Post
 	for rows.Next() {
 		var post repo_models.Post
@@ -129,8 +333,13 @@ func (r *PostRepository) GetPostsByUserId(ctx context.Context, limit int, offset
 			&post.ID,
 			&post.Title,
 			&post.Content,
+			&post.ContentFormat,
 			&post.UserID,
 			&post.Commentable,
+			&post.Status,
+			&post.PublishAt,
+			&post.CommentCount,
+			&post.LastCommentAt,
 		)
 		if err != nil {
 			return nil, err
@@ -138,31 +347,9 @@ func (r *PostRepository) GetPostsByUserId(ctx context.Context, limit int, offset
 		posts = append(posts, &post)
 	}
 
-	return posts, nil
-}
-
-func (r *PostRepository) UpdatePost(ctx context.Context, id int, title, content string, userID int, commentable bool) (*repo_models.Post, error) {
-	var post repo_models.Post
-	row := r.db.QueryRowContext(ctx, UpdatePostQuery, title, content, id, userID, commentable)
-	err := row.Scan(
-		&post.ID,
-		&post.Title,
-		&post.Content,
-		&post.UserID,
-		&post.Commentable,
-	)
-	if err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return &post, nil
-}
-
-func (r *PostRepository) DeletePost(ctx context.Context, id int) error {
-	_, err := r.db.ExecContext(ctx, DeletePostQuery, id)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return posts, nil
 }
@@ -0,0 +1,66 @@
+package pg_repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// statements готовит горячие запросы один раз на базу (основную или реплику).
+// database/sql сам переподготавливает их на новых соединениях пула.
+type statements struct {
+	mu       sync.Mutex
+	prepared map[*sql.DB]map[string]*sql.Stmt
+}
+
+func newStatements() *statements {
+	return &statements{
+		prepared: make(map[*sql.DB]map[string]*sql.Stmt),
+	}
+}
+
+func (s *statements) get(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, error) {
+	s.mu.Lock()
+	stmt, ok := s.prepared[db][query]
+	s.mu.Unlock()
+	if ok {
+		return stmt, nil
+	}
+
+	// подготовка ходит в базу, под мьютексом она задерживала бы все остальные запросы
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// пока готовили, тот же запрос мог подготовить другой вызов - оставляем его вариант
+	if existing, ok := s.prepared[db][query]; ok {
+		stmt.Close()
+		return existing, nil
+	}
+	if s.prepared[db] == nil {
+		s.prepared[db] = make(map[string]*sql.Stmt)
+	}
+	s.prepared[db][query] = stmt
+	return stmt, nil
+}
+
+func (s *statements) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for db, queries := range s.prepared {
+		for _, stmt := range queries {
+			if err := stmt.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		delete(s.prepared, db)
+	}
+	return errors.Join(errs...)
+}
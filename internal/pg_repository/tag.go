@@ -0,0 +1,269 @@
+package pg_repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+	"github.com/lib/pq"
+)
+
+type TagRepository struct {
+	db *Cluster
+}
+
+func NewTagRepository(db *Cluster) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+const (
+	CreateTagsQuery = `
+		INSERT INTO tags (name)
+		SELECT unnest($1::text[])
+		ON CONFLICT (name) DO NOTHING;
+	`
+	DeletePostTagsQuery = `
+		DELETE FROM post_tags
+		WHERE post_id = $1;
+	`
+	AddPostTagsQuery = `
+		INSERT INTO post_tags (post_id, tag_id)
+		SELECT $1, id
+		FROM tags
+		WHERE name = ANY($2)
+		ON CONFLICT DO NOTHING;
+	`
+	GetTagsByPostIdsQuery = `
+		SELECT pt.post_id, t.name
+		FROM post_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.post_id = ANY($1)
+		ORDER BY pt.post_id, t.name;
+	`
+	// keyset-пагинация: $2 - id последнего поста предыдущей страницы (0 - с начала)
+	GetPostsByTagQuery = `
+		SELECT p.id, p.title, p.content, p.content_format, p.user_id, p.commentable, p.status, p.publish_at, p.comment_count, p.last_comment_at
+		FROM posts p
+		JOIN post_tags pt ON pt.post_id = p.id
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE t.name = $1 AND p.status = 'PUBLISHED'
+		AND ($2 = 0 OR p.id < $2)
+		ORDER BY p.id DESC
+		LIMIT $3;
+	`
+	SearchTagsQuery = `
+		SELECT t.id, t.name,
+		(SELECT COUNT(*) FROM post_tags pt JOIN posts p ON p.id = pt.post_id WHERE pt.tag_id = t.id AND p.status = 'PUBLISHED') AS post_count,
+		(SELECT COUNT(*) FROM tag_followers tf WHERE tf.tag_id = t.id) AS follower_count
+		FROM tags t
+		WHERE t.name LIKE $1
+		ORDER BY post_count DESC, t.name
+		LIMIT $2;
+	`
+	GetTagsByNamesQuery = `
+		SELECT t.id, t.name,
+		(SELECT COUNT(*) FROM post_tags pt JOIN posts p ON p.id = pt.post_id WHERE pt.tag_id = t.id AND p.status = 'PUBLISHED'),
+		(SELECT COUNT(*) FROM tag_followers tf WHERE tf.tag_id = t.id)
+		FROM tags t
+		WHERE t.name = ANY($1)
+		ORDER BY t.name;
+	`
+	FollowTagQuery = `
+		INSERT INTO tag_followers (tag_id, user_id)
+		SELECT id, $2
+		FROM tags
+		WHERE name = $1
+		ON CONFLICT DO NOTHING;
+	`
+	UnfollowTagQuery = `
+		DELETE FROM tag_followers
+		WHERE user_id = $2
+		AND tag_id = (SELECT id FROM tags WHERE name = $1);
+	`
+	GetFollowedTagsQuery = `
+		SELECT t.name
+		FROM tag_followers tf
+		JOIN tags t ON t.id = tf.tag_id
+		WHERE tf.user_id = $1
+		ORDER BY t.name;
+	`
+)
+
+// SetPostTags заменяет теги поста, недостающие теги создаются
+func (r *TagRepository) SetPostTags(ctx context.Context, postID int, tags []string) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.writer(ctx).BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, DeletePostTagsQuery, postID); err != nil {
+		return err
+	}
+	if len(tags) > 0 {
+		if _, err := tx.ExecContext(ctx, CreateTagsQuery, pq.Array(tags)); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, AddPostTagsQuery, postID, pq.Array(tags)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *TagRepository) GetTagsByPostIDs(ctx context.Context, postIDs []int) (map[int][]string, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	tags := make(map[int][]string)
+	if len(postIDs) == 0 {
+		return tags, nil
+	}
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, GetTagsByPostIdsQuery, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var name string
+		if err := rows.Scan(&postID, &name); err != nil {
+			return nil, err
+		}
+		tags[postID] = append(tags[postID], name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (r *TagRepository) GetPostsByTag(ctx context.Context, tag string, limit int, afterID int) ([]*repo_models.Post, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, GetPostsByTagQuery, tag, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+func (r *TagRepository) SearchTags(ctx context.Context, prefix string, limit int) ([]*repo_models.Tag, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	return r.queryTags(ctx, SearchTagsQuery, escapeLike(prefix)+"%", limit)
+}
+
+func (r *TagRepository) GetTagsByNames(ctx context.Context, names []string) ([]*repo_models.Tag, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	return r.queryTags(ctx, GetTagsByNamesQuery, pq.Array(names))
+}
+
+func (r *TagRepository) FollowTag(ctx context.Context, userID int, name string) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	res, err := r.db.writer(ctx).ExecContext(ctx, FollowTagQuery, name, userID)
+	if err != nil {
+		return err
+	}
+	// 0 строк - либо уже подписан, либо тега нет
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		tags, err := r.GetTagsByNames(ctx, []string{name})
+		if err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return fmt.Errorf("tag %q not found", name)
+		}
+	}
+
+	return nil
+}
+
+func (r *TagRepository) UnfollowTag(ctx context.Context, userID int, name string) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.writer(ctx).ExecContext(ctx, UnfollowTagQuery, name, userID)
+	return err
+}
+
+func (r *TagRepository) GetFollowedTags(ctx context.Context, userID int) ([]string, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.reader(ctx).QueryContext(ctx, GetFollowedTagsQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+func (r *TagRepository) queryTags(ctx context.Context, query string, args ...any) ([]*repo_models.Tag, error) {
+	rows, err := r.db.reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []*repo_models.Tag
+	for rows.Next() {
+		var tag repo_models.Tag
+		err := rows.Scan(
+			&tag.ID,
+			&tag.Name,
+			&tag.PostCount,
+			&tag.FollowerCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// escapeLike экранирует спецсимволы LIKE, чтобы префикс искался буквально
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
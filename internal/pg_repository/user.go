@@ -3,17 +3,19 @@ package pg_repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 
 	"github.com/AntonCkya/ozon_habr/internal/repo_models"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserRepository struct {
-	db *sql.DB
+	db *Cluster
 }
 
-func NewUserRepository(db *sql.DB) *UserRepository {
+func NewUserRepository(db *Cluster) *UserRepository {
 	return &UserRepository{db: db}
 }
 
@@ -21,36 +23,85 @@ const (
 	CreateUserQuery = `
 		INSERT INTO users (username, password_hash)
 		VALUES ($1, $2)
-		RETURNING id, username;
+		RETURNING id, username, display_name, bio, avatar_url, created_at, token_version;
 	`
 	GetUserByIdQuery = `
-		SELECT id, username, password_hash
+		SELECT id, username, password_hash, display_name, bio, avatar_url, created_at, token_version
 		FROM users 
 		WHERE id = $1;
 	`
 	GetUserByNameQuery = `
-		SELECT id, username, password_hash
+		SELECT id, username, password_hash, display_name, bio, avatar_url, created_at, token_version
 		FROM users 
 		WHERE username = $1;
 	`
 	// для решения N+1
 	GetUsersByIdBulkQuery = `
-        SELECT id, username, password_hash
+        SELECT id, username, password_hash, display_name, bio, avatar_url, created_at, token_version
         FROM users 
         WHERE id = ANY($1);
     `
+	UpdateProfileQuery = `
+		UPDATE users
+		SET
+		display_name = $2,
+		bio = $3,
+		avatar_url = $4
+		WHERE id = $1
+		RETURNING id, username, password_hash, display_name, bio, avatar_url, created_at, token_version;
+	`
+	// смена пароля отзывает все выданные ранее токены
+	UpdatePasswordQuery = `
+		UPDATE users
+		SET
+		password_hash = $2,
+		token_version = token_version + 1
+		WHERE id = $1
+		RETURNING id, username, password_hash, display_name, bio, avatar_url, created_at, token_version;
+	`
+	UpdateUsernameQuery = `
+		UPDATE users
+		SET
+		username = $2
+		WHERE id = $1
+		RETURNING id, username, password_hash, display_name, bio, avatar_url, created_at, token_version;
+	`
+	// аккаунт остаётся ради его постов и комментариев, но войти в него уже нельзя
+	AnonymizeUserQuery = `
+		UPDATE users
+		SET
+		username = 'deleted_' || id,
+		password_hash = '',
+		display_name = '',
+		bio = '',
+		avatar_url = '',
+		token_version = token_version + 1
+		WHERE id = $1;
+	`
+	// посты и комментарии удаляются каскадно
+	// посты, комментарии, уведомления и остальное удаляет ON DELETE CASCADE в той же транзакции
+	DeleteUserQuery = `
+		DELETE FROM users
+		WHERE id = $1;
+	`
 )
 
 func (r *UserRepository) CreateUser(ctx context.Context, username, password string) (*repo_models.User, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
 
 	var user repo_models.User
-	row := r.db.QueryRowContext(ctx, CreateUserQuery, username, string(hashedPassword))
-	err = row.Scan(&user.ID, &user.Username)
+	row := r.db.writer(ctx).QueryRowContext(ctx, CreateUserQuery, username, string(hashedPassword))
+	err = row.Scan(&user.ID, &user.Username, &user.DisplayName, &user.Bio, &user.AvatarURL, &user.CreatedAt, &user.TokenVersion)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, repo_models.ErrUsernameTaken
+		}
 		return nil, err
 	}
 
@@ -58,6 +109,9 @@ func (r *UserRepository) CreateUser(ctx context.Context, username, password stri
 }
 
 func (r *UserRepository) GetUserByID(ctx context.Context, id int) (*repo_models.User, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
 	user, err := r.GetUser(ctx, true, id)
 
 	if err != nil {
@@ -68,6 +122,9 @@ func (r *UserRepository) GetUserByID(ctx context.Context, id int) (*repo_models.
 }
 
 func (r *UserRepository) GetUserByUsername(ctx context.Context, username string) (*repo_models.User, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
 	user, err := r.GetUser(ctx, false, username)
 
 	if err != nil {
@@ -78,6 +135,9 @@ func (r *UserRepository) GetUserByUsername(ctx context.Context, username string)
 }
 
 func (r *UserRepository) GetUser(ctx context.Context, by_id bool, payload any) (*repo_models.User, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
 	var user repo_models.User
 
 	var query string
@@ -87,9 +147,11 @@ func (r *UserRepository) GetUser(ctx context.Context, by_id bool, payload any) (
 		query = GetUserByNameQuery
 	}
 
-	row := r.db.QueryRowContext(ctx, query, payload)
-	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash)
-
+	row := r.db.Primary().QueryRowContext(ctx, query, payload)
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.DisplayName, &user.Bio, &user.AvatarURL, &user.CreatedAt, &user.TokenVersion)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repo_models.ErrUserNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -98,9 +160,16 @@ func (r *UserRepository) GetUser(ctx context.Context, by_id bool, payload any) (
 }
 
 func (r *UserRepository) GetUsersByIDs(ctx context.Context, ids []int) ([]*repo_models.User, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
 	var users []*repo_models.User
 
-	rows, err := r.db.QueryContext(ctx, GetUsersByIdBulkQuery, pq.Array(ids))
+	stmt, err := r.db.prepared(ctx, r.db.reader(ctx), GetUsersByIdBulkQuery)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.QueryContext(ctx, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +177,7 @@ func (r *UserRepository) GetUsersByIDs(ctx context.Context, ids []int) ([]*repo_
 
 	for rows.Next() {
 		var user repo_models.User
-		err := rows.Scan(&user.ID, &user.Username, &user.PasswordHash)
+		err := rows.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.DisplayName, &user.Bio, &user.AvatarURL, &user.CreatedAt, &user.TokenVersion)
 		if err != nil {
 			return nil, err
 		}
@@ -121,3 +190,87 @@ func (r *UserRepository) GetUsersByIDs(ctx context.Context, ids []int) ([]*repo_
 
 	return users, nil
 }
+
+func (r *UserRepository) UpdateProfile(ctx context.Context, id int, displayName, bio, avatarURL string) (*repo_models.User, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var user repo_models.User
+	row := r.db.writer(ctx).QueryRowContext(ctx, UpdateProfileQuery, id, displayName, bio, avatarURL)
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.DisplayName, &user.Bio, &user.AvatarURL, &user.CreatedAt, &user.TokenVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int, password string) (*repo_models.User, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	var user repo_models.User
+	row := r.db.writer(ctx).QueryRowContext(ctx, UpdatePasswordQuery, id, string(hashedPassword))
+	err = row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.DisplayName, &user.Bio, &user.AvatarURL, &user.CreatedAt, &user.TokenVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *UserRepository) UpdateUsername(ctx context.Context, id int, username string) (*repo_models.User, error) {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	var user repo_models.User
+	row := r.db.writer(ctx).QueryRowContext(ctx, UpdateUsernameQuery, id, username)
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.DisplayName, &user.Bio, &user.AvatarURL, &user.CreatedAt, &user.TokenVersion)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, repo_models.ErrUsernameTaken
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *UserRepository) AnonymizeUser(ctx context.Context, id int) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.writer(ctx).ExecContext(ctx, AnonymizeUserQuery, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *UserRepository) DeleteUser(ctx context.Context, id int) error {
+	ctx, cancel := r.db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.writer(ctx).ExecContext(ctx, DeleteUserQuery, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ошибки у lib/pq и pgx разные, проверяем обе
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
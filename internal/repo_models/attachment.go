@@ -0,0 +1,14 @@
+package repo_models
+
+// Attachment - загруженная картинка. PostID nil, пока вложение не привязано к посту.
+type Attachment struct {
+	ID           int    `json:"id"`
+	UserID       int    `json:"userId"`
+	PostID       *int   `json:"postId"`
+	Key          string `json:"key"`
+	ThumbnailKey string `json:"thumbnailKey"`
+	ContentType  string `json:"contentType"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
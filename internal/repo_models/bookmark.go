@@ -0,0 +1,8 @@
+package repo_models
+
+// Bookmark - пост в закладках пользователя, ID служит курсором списка закладок
+type Bookmark struct {
+	ID     int   `json:"id"`
+	UserID int   `json:"userId"`
+	Post   *Post `json:"post"`
+}
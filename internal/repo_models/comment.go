@@ -1,9 +1,12 @@
 package repo_models
 
 type Comment struct {
-	ID       int    `json:"id"`
-	Content  string `json:"content"`
-	UserID   int    `json:"userId"`
-	PostID   int    `json:"postId"`
-	ParentID *int   `json:"parentId,omitempty"`
+	ID            int    `json:"id"`
+	Content       string `json:"content"`
+	ContentFormat string `json:"contentFormat"`
+	UserID        int    `json:"userId"`
+	PostID        int    `json:"postId"`
+	ParentID      *int   `json:"parentId,omitempty"`
+	// id события в журнале поста, заполняется только при создании
+	EventID int `json:"eventId,omitempty"`
 }
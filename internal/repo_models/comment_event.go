@@ -0,0 +1,7 @@
+package repo_models
+
+type CommentEvent struct {
+	ID      int      `json:"id"`
+	PostID  int      `json:"postId"`
+	Comment *Comment `json:"comment"`
+}
@@ -0,0 +1,7 @@
+package repo_models
+
+// формат текста постов и комментариев
+const (
+	ContentFormatPlain    = "PLAIN"
+	ContentFormatMarkdown = "MARKDOWN"
+)
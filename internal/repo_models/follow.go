@@ -0,0 +1,17 @@
+package repo_models
+
+import "time"
+
+// Follow - FollowerID подписан на UserID, ID служит курсором списков подписок
+type Follow struct {
+	ID         int `json:"id"`
+	FollowerID int `json:"followerId"`
+	UserID     int `json:"userId"`
+}
+
+// FeedCursor - позиция в ленте. Лента идёт по времени публикации от новых к старым,
+// при равном времени - по id: запланированный пост публикуется позже, чем создан.
+type FeedCursor struct {
+	PublishAt time.Time `json:"publishAt"`
+	ID        int       `json:"id"`
+}
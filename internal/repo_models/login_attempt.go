@@ -0,0 +1,11 @@
+package repo_models
+
+import "time"
+
+// LoginAttempts - состояние неудачных попыток входа по ключу (имя пользователя или IP)
+type LoginAttempts struct {
+	Key           string    `json:"key"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"lastFailureAt"`
+	LockedUntil   time.Time `json:"lockedUntil"`
+}
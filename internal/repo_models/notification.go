@@ -0,0 +1,20 @@
+package repo_models
+
+import "time"
+
+const (
+	NotificationCommentReply = "COMMENT_REPLY"
+	NotificationPostComment  = "POST_COMMENT"
+	NotificationMention      = "MENTION"
+)
+
+type Notification struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"userId"`
+	ActorID   int       `json:"actorId"`
+	Type      string    `json:"type"`
+	PostID    int       `json:"postId"`
+	CommentID int       `json:"commentId"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -1,9 +1,31 @@
 package repo_models
 
+import "time"
+
+const (
+	PostStatusDraft     = "DRAFT"
+	PostStatusScheduled = "SCHEDULED"
+	PostStatusPublished = "PUBLISHED"
+	PostStatusArchived  = "ARCHIVED"
+)
+
+// порядок списка постов, пустая строка - порядок по умолчанию
+const (
+	PostOrderMostDiscussed     = "MOST_DISCUSSED"
+	PostOrderRecentlyDiscussed = "RECENTLY_DISCUSSED"
+)
+
 type Post struct {
-	ID          int    `json:"id"`
-	Title       string `json:"title"`
-	Content     string `json:"content"`
-	UserID      int    `json:"userId"`
-	Commentable bool   `json:"commentable"`
+	ID            int    `json:"id"`
+	Title         string `json:"title"`
+	Content       string `json:"content"`
+	ContentFormat string `json:"contentFormat"`
+	UserID        int    `json:"userId"`
+	Commentable   bool   `json:"commentable"`
+	Status        string `json:"status"`
+	// для SCHEDULED - запланированное время, для опубликованных - время публикации
+	PublishAt *time.Time `json:"publishAt"`
+	// ведутся базой при создании и удалении комментариев
+	CommentCount  int        `json:"commentCount"`
+	LastCommentAt *time.Time `json:"lastCommentAt"`
 }
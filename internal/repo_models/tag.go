@@ -0,0 +1,8 @@
+package repo_models
+
+type Tag struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	PostCount     int    `json:"postCount"`
+	FollowerCount int    `json:"followerCount"`
+}
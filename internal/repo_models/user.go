@@ -1,13 +1,28 @@
 package repo_models
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 type User struct {
-	ID           int    `json:"id"`
-	Username     string `json:"username"`
-	PasswordHash string `json:"-"`
+	ID           int       `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	DisplayName  string    `json:"displayName"`
+	Bio          string    `json:"bio"`
+	AvatarURL    string    `json:"avatarUrl"`
+	CreatedAt    time.Time `json:"createdAt"`
+	// увеличивается при смене пароля, старые токены с другой версией считаются отозванными
+	TokenVersion int `json:"-"`
 }
 
+var ErrUsernameTaken = errors.New("username already taken")
+
+var ErrUserNotFound = errors.New("user not found")
+
 func (u *User) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
 	return err == nil
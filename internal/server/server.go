@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+	"time"
+)
+
+type Timeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+// New создаёт http.Server с таймаутами. Для SSE и вебсокетов таймауты
+// чтения и записи снимает Streams.Middleware, поэтому он должен быть снаружи handler.
+func New(addr string, handler http.Handler, timeouts Timeouts) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		ReadTimeout:       timeouts.Read,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Streams отслеживает долгие соединения (SSE и вебсокеты): снимает с них таймауты сервера
+// и при остановке завершает подписки. http.Server.Shutdown сам их не закрывает:
+// SSE-запрос никогда не станет idle, а вебсокет после Hijack серверу уже не принадлежит.
+type Streams struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func NewStreams() *Streams {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Streams{
+		ctx:    ctx,
+		cancel: cancel,
+		conns:  make(map[net.Conn]struct{}),
+	}
+}
+
+func isStream(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+func (s *Streams) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isStream(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		s.wg.Add(1)
+		defer s.wg.Done()
+
+		controller := http.NewResponseController(w)
+		controller.SetReadDeadline(time.Time{})
+		controller.SetWriteDeadline(time.Time{})
+
+		// подписки завершаются по отмене контекста запроса
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stop := context.AfterFunc(s.ctx, cancel)
+		defer stop()
+
+		next.ServeHTTP(&streamResponseWriter{ResponseWriter: w, streams: s}, r.WithContext(ctx))
+	})
+}
+
+// Close отменяет контексты всех подписок и прерывает чтение из вебсокетов,
+// чтобы их обработчики завершились. Вызывается через http.Server.RegisterOnShutdown.
+func (s *Streams) Close() {
+	s.cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+}
+
+// Wait ждёт завершения обработчиков долгих соединений
+func (s *Streams) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Streams) track(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns[conn] = struct{}{}
+	// соединение открылось уже во время остановки
+	if s.ctx.Err() != nil {
+		conn.SetReadDeadline(time.Now())
+	}
+}
+
+func (s *Streams) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+type streamResponseWriter struct {
+	http.ResponseWriter
+	streams *Streams
+}
+
+func (w *streamResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *streamResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.streams.track(conn)
+	return &trackedConn{Conn: conn, streams: w.streams}, brw, nil
+}
+
+func (w *streamResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type trackedConn struct {
+	net.Conn
+	streams *Streams
+	once    sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() { c.streams.untrack(c.Conn) })
+	return c.Conn.Close()
+}
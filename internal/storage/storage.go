@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore хранит файлы вложений по ключу.
+// URL отдаёт адрес, по которому клиент может скачать файл.
+type BlobStore interface {
+	Put(ctx context.Context, key string, contentType string, r io.Reader) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+// NewKey возвращает случайный ключ с расширением ext (".png")
+func NewKey(ext string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b) + ext
+}
@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GraphQL - трейсер gqlgen: спан на операцию и дочерние спаны на резолверы.
+// Тривиальные поля (чтение из структуры) спанов не получают.
+type GraphQL struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+	graphql.FieldInterceptor
+} = GraphQL{}
+
+func (GraphQL) ExtensionName() string {
+	return "Tracing"
+}
+
+func (GraphQL) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (GraphQL) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	opCtx := graphql.GetOperationContext(ctx)
+
+	operationType := "unknown"
+	if opCtx.Operation != nil {
+		operationType = string(opCtx.Operation.Operation)
+	}
+	name := opCtx.OperationName
+	if name == "" {
+		name = "anonymous"
+	}
+
+	ctx, span := Tracer().Start(ctx, "graphql "+operationType+" "+name,
+		trace.WithAttributes(
+			attribute.String("graphql.operation.name", opCtx.OperationName),
+			attribute.String("graphql.operation.type", operationType),
+		),
+	)
+
+	responses := next(ctx)
+	return func(ctx context.Context) *graphql.Response {
+		response := responses(ctx)
+		if response != nil && len(response.Errors) > 0 {
+			span.SetStatus(codes.Error, response.Errors.Error())
+		}
+		// подписка отдаёт ответ на каждое событие, спан закрывается вместе с ней
+		if response == nil || operationType != "subscription" {
+			span.End()
+		}
+		return response
+	}
+}
+
+func (GraphQL) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+
+	ctx, span := Tracer().Start(ctx, fc.Object+"."+fc.Field.Name,
+		trace.WithAttributes(
+			attribute.String("graphql.field.path", fc.Path().String()),
+		),
+	)
+	defer span.End()
+
+	result, err := next(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return result, err
+}
@@ -0,0 +1,28 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Extract достаёт контекст трейса из хэдеров traceparent/tracestate
+func Extract(r *http.Request) context.Context {
+	return otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+}
+
+// StartHTTPSpan открывает серверный спан запроса, дочерний к входящему traceparent
+func StartHTTPSpan(r *http.Request) (context.Context, trace.Span) {
+	return Tracer().Start(Extract(r), "HTTP "+r.Method+" "+r.URL.Path,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("user_agent.original", r.UserAgent()),
+		),
+	)
+}
@@ -0,0 +1,156 @@
+package validation
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 32
+	MinPasswordLength = 8
+	// bcrypt молча обрезает всё, что длиннее 72 байт
+	MaxPasswordBytes = 72
+)
+
+// буквы, цифры и подчёркивание - те же символы, что распознаются в @упоминаниях
+var usernameRegexp = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+
+var reservedUsernames = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"root":          true,
+	"system":        true,
+	"support":       true,
+	"moderator":     true,
+	"habr":          true,
+	"ozon":          true,
+	"me":            true,
+	"null":          true,
+	"undefined":     true,
+	"anonymous":     true,
+	"deleted":       true,
+}
+
+// такой префикс получают анонимизированные аккаунты, см. AnonymizeUser
+const deletedUsernamePrefix = "deleted_"
+
+//go:embed breached_passwords.txt
+var embeddedBreachedPasswords string
+
+// FieldErrors - ошибки валидации по полям запроса
+type FieldErrors map[string][]string
+
+func (e FieldErrors) Add(field string, message string) {
+	e[field] = append(e[field], message)
+}
+
+type Validator struct {
+	breachedPasswords map[string]bool
+}
+
+// New создаёт валидатор со встроенным списком утёкших паролей.
+// Если задан breachedPasswordsPath, список дополняется паролями из файла (по одному на строку).
+func New(breachedPasswordsPath string) (*Validator, error) {
+	v := &Validator{breachedPasswords: make(map[string]bool)}
+	v.addBreachedPasswords(bufio.NewScanner(strings.NewReader(embeddedBreachedPasswords)))
+
+	if breachedPasswordsPath != "" {
+		file, err := os.Open(breachedPasswordsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open breached passwords file: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		v.addBreachedPasswords(scanner)
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read breached passwords file: %w", err)
+		}
+	}
+
+	return v, nil
+}
+
+func (v *Validator) addBreachedPasswords(scanner *bufio.Scanner) {
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		v.breachedPasswords[strings.ToLower(line)] = true
+	}
+}
+
+// NormalizeUsername приводит имя к NFKC, чтобы визуально одинаковые имена совпадали
+func NormalizeUsername(username string) string {
+	return strings.TrimSpace(norm.NFKC.String(username))
+}
+
+// ValidateUsername проверяет уже нормализованное имя пользователя
+func (v *Validator) ValidateUsername(username string, errs FieldErrors) {
+	length := utf8.RuneCountInString(username)
+	if length < MinUsernameLength || length > MaxUsernameLength {
+		errs.Add("username", fmt.Sprintf("must be from %d to %d characters long", MinUsernameLength, MaxUsernameLength))
+	}
+	if username != "" && !usernameRegexp.MatchString(username) {
+		errs.Add("username", "may contain only letters, digits and underscores")
+	}
+
+	lower := strings.ToLower(username)
+	if reservedUsernames[lower] || strings.HasPrefix(lower, deletedUsernamePrefix) {
+		errs.Add("username", "is reserved")
+	}
+}
+
+func (v *Validator) ValidatePassword(password string, username string, errs FieldErrors) {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		errs.Add("password", fmt.Sprintf("must be at least %d characters long", MinPasswordLength))
+	}
+	if len(password) > MaxPasswordBytes {
+		errs.Add("password", fmt.Sprintf("must be at most %d bytes long", MaxPasswordBytes))
+	}
+	if passwordClasses(password) < 2 {
+		errs.Add("password", "must contain at least two of: lowercase letters, uppercase letters, digits, symbols")
+	}
+
+	lower := strings.ToLower(password)
+	if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		errs.Add("password", "must not contain the username")
+	}
+	if v.breachedPasswords[lower] {
+		errs.Add("password", "is too common and appears in known data breaches")
+	}
+}
+
+func passwordClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, symbol} {
+		if ok {
+			classes++
+		}
+	}
+	return classes
+}
@@ -0,0 +1,143 @@
+package wsutil
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/AntonCkya/ozon_habr/internal/logging"
+)
+
+// gqlgen не даёт доступа к *websocket.Conn, поэтому SetReadLimit не вызвать.
+// Вместо этого оборачиваем соединение после Hijack и следим за заголовками входящих фреймов.
+// Важно: у Upgrader должен быть задан ReadBufferSize, иначе gorilla переиспользует
+// буфер из Hijack и читает мимо обёртки.
+
+var ErrMessageTooBig = errors.New("websocket message too big")
+
+// LimitMessageSize ограничивает размер входящих вебсокет-сообщений (с учётом фрагментации)
+func LimitMessageSize(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limit <= 0 || r.Header.Get("Upgrade") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&limitResponseWriter{ResponseWriter: w, limit: limit, logger: logging.FromContext(r.Context())}, r)
+	})
+}
+
+type limitResponseWriter struct {
+	http.ResponseWriter
+	limit  int64
+	logger *slog.Logger
+}
+
+func (w *limitResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &limitConn{Conn: conn, limit: w.limit, logger: w.logger}, brw, nil
+}
+
+type limitConn struct {
+	net.Conn
+	limit  int64
+	logger *slog.Logger
+
+	header    []byte // байты заголовка текущего фрейма
+	remaining int64  // сколько байт payload текущего фрейма ещё не прочитано
+	message   int64  // размер текущего (возможно фрагментированного) сообщения
+}
+
+func (c *limitConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		if limitErr := c.observe(p[:n]); limitErr != nil {
+			c.logger.Warn("closing websocket", "remote_addr", c.RemoteAddr().String(), "error", limitErr)
+			c.Conn.Close()
+			return 0, limitErr
+		}
+	}
+	return n, err
+}
+
+func (c *limitConn) observe(data []byte) error {
+	for len(data) > 0 {
+		if c.remaining > 0 {
+			skip := int64(len(data))
+			if skip > c.remaining {
+				skip = c.remaining
+			}
+			c.remaining -= skip
+			data = data[skip:]
+			continue
+		}
+
+		c.header = append(c.header, data[0])
+		data = data[1:]
+		size, complete := frameHeaderSize(c.header)
+		if !complete || len(c.header) < size {
+			continue
+		}
+
+		fin := c.header[0]&0x80 != 0
+		opcode := c.header[0] & 0x0f
+		length := framePayloadLength(c.header)
+		c.header = c.header[:0]
+		c.remaining = length
+
+		// управляющие фреймы (close, ping, pong) в сообщение не входят
+		if opcode >= 0x8 {
+			continue
+		}
+		if opcode != 0x0 {
+			c.message = 0
+		}
+		c.message += length
+		if c.message > c.limit {
+			return fmt.Errorf("%w: %d > %d bytes", ErrMessageTooBig, c.message, c.limit)
+		}
+		if fin {
+			c.message = 0
+		}
+	}
+	return nil
+}
+
+// frameHeaderSize возвращает полный размер заголовка, если по первым байтам его уже можно определить
+func frameHeaderSize(header []byte) (int, bool) {
+	if len(header) < 2 {
+		return 0, false
+	}
+	size := 2
+	switch header[1] & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if header[1]&0x80 != 0 {
+		size += 4
+	}
+	return size, true
+}
+
+func framePayloadLength(header []byte) int64 {
+	switch length := header[1] & 0x7f; length {
+	case 126:
+		return int64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		return int64(binary.BigEndian.Uint64(header[2:10]))
+	default:
+		return int64(length)
+	}
+}
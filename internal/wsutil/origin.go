@@ -0,0 +1,23 @@
+package wsutil
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CheckOrigin проверяет Origin вебсокет-запроса по тому же списку, что и CORS
+func CheckOrigin(allowedOrigins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		// не браузерные клиенты Origin не присылают
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range allowedOrigins {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -1,3 +1,10 @@
+-- версии применённых миграций. Таблица создаётся первой: по ней разовые шаги ниже
+-- (заполнение данных) проверяют, выполнялись ли они уже
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
 CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
     username VARCHAR(255) UNIQUE NOT NULL,
@@ -22,3 +29,214 @@ CREATE TABLE IF NOT EXISTS comments (
 
 CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments(post_id);
 CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments(parent_id);
+
+-- журнал событий комментариев для досылки после переподключения подписки
+CREATE TABLE IF NOT EXISTS comment_events (
+    id BIGSERIAL PRIMARY KEY,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id INTEGER NOT NULL REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_events_post_id ON comment_events(post_id, id);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(32) NOT NULL,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id INTEGER NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    read BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, id);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
+
+-- состояние неудачных попыток входа (ключ - "user:<имя>" или "ip:<адрес>")
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key VARCHAR(255) PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ NOT NULL
+);
+
+-- automatic persisted queries: sha256 текста запроса -> запрос
+CREATE TABLE IF NOT EXISTS persisted_queries (
+    hash CHAR(64) PRIMARY KEY,
+    query TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- теги (хабы) постов
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(32) NOT NULL UNIQUE
+);
+
+-- text_pattern_ops нужен для поиска по префиксу (LIKE 'go%') при автодополнении
+CREATE INDEX IF NOT EXISTS idx_tags_name_prefix ON tags(name text_pattern_ops);
+
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (post_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_tags_tag_id ON post_tags(tag_id, post_id);
+
+CREATE TABLE IF NOT EXISTS tag_followers (
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tag_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_followers_user_id ON tag_followers(user_id);
+
+-- статус поста: DRAFT, SCHEDULED, PUBLISHED, ARCHIVED; publish_at - время (запланированной) публикации
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'PUBLISHED';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS publish_at TIMESTAMPTZ;
+
+-- планировщик ищет запланированные посты, у которых наступило время
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled ON posts(publish_at) WHERE status = 'SCHEDULED';
+CREATE INDEX IF NOT EXISTS idx_posts_user_status ON posts(user_id, status);
+
+-- формат текста: PLAIN или MARKDOWN
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_format VARCHAR(16) NOT NULL DEFAULT 'PLAIN';
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS content_format VARCHAR(16) NOT NULL DEFAULT 'PLAIN';
+
+-- вложения: файлы лежат в BlobStore, здесь только метаданные.
+-- Загруженное, но не привязанное к посту вложение имеет post_id NULL.
+CREATE TABLE IF NOT EXISTS attachments (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id INTEGER REFERENCES posts(id) ON DELETE SET NULL,
+    blob_key VARCHAR(255) NOT NULL,
+    thumbnail_key VARCHAR(255) NOT NULL,
+    content_type VARCHAR(64) NOT NULL,
+    size BIGINT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_post_id ON attachments(post_id);
+
+-- закладки; id задаёт порядок в списке закладок (последние добавленные первыми)
+CREATE TABLE IF NOT EXISTS bookmarks (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, post_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_id ON bookmarks(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_post_id ON bookmarks(post_id);
+
+-- подписки на авторов: follower_id подписан на followee_id, id задаёт порядок в списках подписок
+CREATE TABLE IF NOT EXISTS follows (
+    id SERIAL PRIMARY KEY,
+    follower_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follows_follower_id ON follows(follower_id, id);
+CREATE INDEX IF NOT EXISTS idx_follows_followee_id ON follows(followee_id, id);
+
+-- счётчик комментариев и время последнего комментария хранятся в посте,
+-- их ведёт триггер в той же транзакции, что и вставку/удаление комментария (в том числе каскадное)
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS comment_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS last_comment_at TIMESTAMPTZ;
+
+CREATE OR REPLACE FUNCTION update_post_comment_stats() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE posts
+        SET comment_count = comment_count + 1,
+            last_comment_at = GREATEST(last_comment_at, NEW.created_at)
+        WHERE id = NEW.post_id;
+        RETURN NEW;
+    END IF;
+
+    -- при удалении поста строки уже нет, и UPDATE ничего не делает
+    UPDATE posts
+    SET comment_count = comment_count - 1,
+        last_comment_at = (SELECT MAX(created_at) FROM comments WHERE post_id = OLD.post_id)
+    WHERE id = OLD.post_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER comments_post_stats
+AFTER INSERT OR DELETE ON comments
+FOR EACH ROW EXECUTE FUNCTION update_post_comment_stats();
+
+-- заполнение для уже существующих комментариев, один раз при переходе на версию 8.
+-- Дальше счётчики ведёт триггер, а разошедшиеся пересчитывает ./ozon_habr -repair тем же запросом
+DO $$
+BEGIN
+    IF (SELECT COALESCE(MAX(version), 0) FROM schema_migrations) < 8 THEN
+        UPDATE posts p
+        SET comment_count = s.comment_count, last_comment_at = s.last_comment_at
+        FROM (
+            SELECT p2.id, COUNT(c.id) AS comment_count, MAX(c.created_at) AS last_comment_at
+            FROM posts p2
+            LEFT JOIN comments c ON c.post_id = p2.id
+            GROUP BY p2.id
+        ) s
+        WHERE p.id = s.id
+        AND (p.comment_count <> s.comment_count OR p.last_comment_at IS DISTINCT FROM s.last_comment_at);
+    END IF;
+END;
+$$;
+
+-- сортировки "самые обсуждаемые" и "недавно обсуждали"
+CREATE INDEX IF NOT EXISTS idx_posts_comment_count ON posts(comment_count DESC, id DESC) WHERE status = 'PUBLISHED';
+CREATE INDEX IF NOT EXISTS idx_posts_last_comment_at ON posts(last_comment_at DESC NULLS LAST, id DESC) WHERE status = 'PUBLISHED';
+
+-- лента идёт по времени публикации: запланированный пост публикуется позже, чем создан.
+-- У постов, опубликованных до появления статусов, времени публикации нет, а время создания постов не хранится.
+-- Они старше всех постов со временем, поэтому один раз, при переходе на версию 9, ставим их
+-- перед самым ранним опубликованным, сохраняя порядок по id.
+DO $$
+BEGIN
+    IF (SELECT COALESCE(MAX(version), 0) FROM schema_migrations) < 9 THEN
+        UPDATE posts p
+        SET publish_at = l.publish_at
+        FROM (
+            SELECT id,
+                COALESCE(
+                    (SELECT MIN(publish_at) FROM posts WHERE status IN ('PUBLISHED', 'ARCHIVED')),
+                    NOW()
+                ) - ROW_NUMBER() OVER (ORDER BY id DESC) * INTERVAL '1 second' AS publish_at
+            FROM posts
+            WHERE publish_at IS NULL AND status IN ('PUBLISHED', 'ARCHIVED')
+        ) l
+        WHERE p.id = l.id;
+    END IF;
+END;
+$$;
+DROP INDEX IF EXISTS idx_posts_user_published;
+CREATE INDEX IF NOT EXISTS idx_posts_user_published_at ON posts(user_id, publish_at DESC, id DESC) WHERE status = 'PUBLISHED';
+CREATE INDEX IF NOT EXISTS idx_posts_published_at ON posts(publish_at DESC, id DESC) WHERE status = 'PUBLISHED';
+
+-- фоновая очистка ищет непривязанные вложения старше ATTACHMENT_TTL
+CREATE INDEX IF NOT EXISTS idx_attachments_unattached ON attachments(created_at) WHERE post_id IS NULL;
+
+-- версия схемы, проверяется в /readyz (db.SchemaVersion).
+-- Скрипт выполняется при каждом старте сервиса (db.Migrate), поэтому все шаги должны быть идемпотентными,
+-- а разовые заполнения данных - проверять версию в schema_migrations.
+-- Новые миграции добавляются выше, версия увеличивается вместе с db.SchemaVersion.
+INSERT INTO schema_migrations (version) VALUES (10) ON CONFLICT DO NOTHING;
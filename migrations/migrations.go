@@ -0,0 +1,9 @@
+package migrations
+
+import _ "embed"
+
+// InitSQL - схема целиком. Все шаги идемпотентны, поэтому скрипт можно применять
+// к базе любой версии: при старте с -s p его выполняет db.Migrate.
+//
+//go:embed init.sql
+var InitSQL string